@@ -0,0 +1,236 @@
+// Command rcadmin operates a running rclone instance directly through its
+// databases.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	osuser "os/user"
+	"strings"
+	"time"
+
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/config"
+	"github.com/teatah/rclone/pkg/databases/mongodb"
+	"github.com/teatah/rclone/pkg/databases/postgres"
+	"github.com/teatah/rclone/pkg/migrate"
+	"github.com/teatah/rclone/pkg/post"
+	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+const usage = `Usage: rcadmin [config flags] <command> [arguments]
+
+Commands:
+  users list [-offset N] [-limit N]      list users
+  users ban <username>                   ban a user and revoke their sessions
+  users unban <username>                 lift a ban
+  users delete <username>                delete a user with their sessions and messages
+  users reset-password [-password P] <username>
+                                         set a new password, random unless given
+  users set-email [-email E] <username>  set a verified email address, removed
+                                         when E is empty
+  sessions revoke <username>             log a user out everywhere
+  posts deleted [-offset N] [-limit N]   list deleted posts
+  posts delete <postID>                  move a post to the trash
+  posts restore <postID>                 bring a deleted post back
+  stats                                  print instance statistics
+  migrate up                             apply pending migrations
+  migrate down [-db D] [-steps N]        revert the last N migrations of D
+                                         (postgres or mongo)
+  migrate status                         list migrations and when they were applied
+
+The database settings are loaded like the server does: from the config file,
+the environment and .env, and the config flags, which are listed by rcadmin -h.
+`
+
+// app holds what the commands operate on.
+type app struct {
+	userRepo user.UserRepo
+	postRepo post.PostRepo
+	sm       *session.DBSessionManager
+	migrate  []migrate.Named
+	audit    *audit.Recorder
+	actor    string
+	out      io.Writer
+	// memorySessions is set when the server keeps sessions in its memory,
+	// out of reach of rcadmin; tokenTTL is how long their access tokens work.
+	memorySessions bool
+	tokenTTL       time.Duration
+}
+
+type command func(ctx context.Context, a *app, args []string) error
+
+var commands = map[string]command{
+	"users list":           listUsers,
+	"users ban":            banUser,
+	"users unban":          unbanUser,
+	"users delete":         deleteUser,
+	"users reset-password": resetPassword,
+	"users set-email":      setEmail,
+	"sessions revoke":      revokeSessions,
+	"posts deleted":        listDeletedPosts,
+	"posts delete":         deletePost,
+	"posts restore":        restorePost,
+	"stats":                printStats,
+	"migrate up":           migrateUp,
+	"migrate down":         migrateDown,
+	"migrate status":       migrateStatus,
+}
+
+func main() {
+	config, rest, err := config.Load("rcadmin", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rcadmin: failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd, args := lookupCommand(rest)
+	if cmd == nil {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	err = run(config, cmd, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rcadmin: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// lookupCommand finds the command named by the first one or two arguments and
+// returns it with the remaining arguments.
+func lookupCommand(args []string) (command, []string) {
+	if len(args) >= 2 {
+		cmd, ok := commands[args[0]+" "+args[1]]
+		if ok {
+			return cmd, args[2:]
+		}
+	}
+
+	if len(args) >= 1 {
+		cmd, ok := commands[args[0]]
+		if ok {
+			return cmd, args[1:]
+		}
+	}
+
+	return nil, nil
+}
+
+func run(config *config.Config, cmd command, args []string) error {
+	logger := zap.NewExample()
+	defer func() {
+		err := logger.Sync()
+		if err != nil {
+			log.Print(err)
+		}
+	}()
+	sugar := logger.Sugar()
+
+	ctx := context.Background()
+	pgPool, err := postgres.ConnectPool(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed connect to postgres: %w", err)
+	}
+	defer pgPool.Close()
+
+	mongoClient, err := mongodb.Connect(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed connect to mongo: %w", err)
+	}
+	defer func() {
+		disconErr := mongoClient.Disconnect(ctx)
+		if disconErr != nil {
+			sugar.Errorf("failed to disconnect from mongo: %s", disconErr)
+		}
+	}()
+
+	db := mongoClient.Database(config.MongoDB.Name)
+
+	migrators, err := migrate.All(pgPool, db)
+	if err != nil {
+		return err
+	}
+
+	userRepo := user.NewUserDBRepo(pgPool)
+	postRepo := post.NewPostDBRepo(
+		db.Collection("posts"),
+		db.Collection("views"),
+		db.Collection("deleted_posts"),
+		userRepo,
+		nil,
+	)
+	postRepo.Logger = sugar
+
+	a := &app{
+		userRepo:       userRepo,
+		postRepo:       postRepo,
+		sm:             session.NewDBSessionManager(pgPool, session.NewPGStore(pgPool)),
+		migrate:        migrators,
+		audit:          audit.NewRecorder(audit.NewAuditDBRepo(pgPool), sugar),
+		actor:          actorName(),
+		out:            os.Stdout,
+		memorySessions: config.Sessions.InMemory(),
+		tokenTTL:       config.Auth.TokenTTL,
+	}
+
+	ctx = audit.WithClient(ctx, &audit.Client{UserAgent: "rcadmin"})
+
+	return cmd(ctx, a, args)
+}
+
+// actorName identifies the operator in the audit log.
+func actorName() string {
+	u, err := osuser.Current()
+	if err != nil {
+		return "rcadmin"
+	}
+
+	return "rcadmin:" + u.Username
+}
+
+// record stores an audit entry for an action of the operator.
+func (a *app) record(ctx context.Context, action, targetType, targetID string, before, after any) {
+	entry := audit.NewEntry(action, targetType, targetID)
+	entry.Actor = a.actor
+	entry.Before = audit.Snapshot(before)
+	entry.After = audit.Snapshot(after)
+	a.audit.Record(ctx, entry)
+}
+
+// oneArg checks that exactly one positional argument named name is left.
+func oneArg(args []string, name string) (string, error) {
+	if len(args) != 1 || len(strings.TrimSpace(args[0])) == 0 {
+		return "", fmt.Errorf("expected a single %s argument", name)
+	}
+
+	return args[0], nil
+}
+
+// revokeSessions ends the sessions of the user and returns how many there
+// were. With SESSION_STORE=memory only the refresh tokens can be revoked, so
+// it warns that issued access tokens keep working until they expire.
+func (a *app) revokeSessions(ctx context.Context, userID string) (int64, error) {
+	revoked, err := a.sm.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if a.memorySessions {
+		fmt.Fprintf(a.out, "warning: sessions are kept in the memory of the server (SESSION_STORE=memory); "+
+			"their refresh tokens are revoked, but issued access tokens work for up to %s until they expire\n", a.tokenTTL)
+	}
+
+	return revoked, nil
+}
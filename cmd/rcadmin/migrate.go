@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+func migrateUp(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("migrate up takes no arguments")
+	}
+
+	for _, m := range a.migrate {
+		applied, err := m.Migrator.Up(ctx)
+		for _, version := range applied {
+			fmt.Fprintf(a.out, "applied %s migration %04d\n", m.Name, version)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Fprintf(a.out, "%s is up to date\n", m.Name)
+		}
+	}
+
+	return nil
+}
+
+func migrateDown(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	db := fs.String("db", "postgres", "database to migrate: postgres or mongo")
+	steps := fs.Int("steps", 1, "number of migrations to revert")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range a.migrate {
+		if m.Name != *db {
+			continue
+		}
+
+		reverted, err := m.Migrator.Down(ctx, *steps)
+		for _, version := range reverted {
+			fmt.Fprintf(a.out, "reverted %s migration %04d\n", m.Name, version)
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("unknown database %q", *db)
+}
+
+func migrateStatus(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("migrate status takes no arguments")
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATABASE\tVERSION\tNAME\tAPPLIED")
+	for _, m := range a.migrate {
+		statuses, err := m.Migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied != nil {
+				applied = s.Applied.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%04d\t%s\t%s\n", m.Name, s.Version, s.Name, applied)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/teatah/rclone/pkg/audit"
+)
+
+func listDeletedPosts(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("posts deleted", flag.ContinueOnError)
+	offset := fs.Int("offset", 0, "number of posts to skip")
+	limit := fs.Int("limit", defaultListLimit, "maximum number of posts to list")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	deletedPosts, err := a.postRepo.DeletedPosts(ctx, *offset, *limit)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDELETED\tAUTHOR\tCATEGORY\tTITLE")
+	for _, p := range *deletedPosts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			p.ID, p.Deleted.Format(time.RFC3339), p.Author.Username, p.Category, p.Title)
+	}
+
+	return tw.Flush()
+}
+
+func deletePost(ctx context.Context, a *app, args []string) error {
+	postID, err := oneArg(args, "post ID")
+	if err != nil {
+		return err
+	}
+
+	deletedPost, err := a.postRepo.DeletePost(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	a.record(ctx, audit.ActionPostDelete, audit.TargetPost, postID, deletedPost, nil)
+
+	fmt.Fprintf(a.out, "deleted post %s %q\n", postID, deletedPost.Title)
+
+	return nil
+}
+
+func restorePost(ctx context.Context, a *app, args []string) error {
+	postID, err := oneArg(args, "post ID")
+	if err != nil {
+		return err
+	}
+
+	restoredPost, err := a.postRepo.RestorePost(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	a.record(ctx, audit.ActionPostRestore, audit.TargetPost, postID, nil, restoredPost)
+
+	fmt.Fprintf(a.out, "restored post %s %q\n", postID, restoredPost.Title)
+
+	return nil
+}
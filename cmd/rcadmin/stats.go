@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+)
+
+func printStats(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("stats takes no arguments")
+	}
+
+	users, banned, err := a.userRepo.CountUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	sessions := "kept in server memory"
+	if !a.memorySessions {
+		count, err := a.sm.CountActiveSessions(ctx)
+		if err != nil {
+			return err
+		}
+		sessions = strconv.Itoa(count)
+	}
+
+	posts, comments, err := a.postRepo.CountPosts(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "users\t%d\n", users)
+	fmt.Fprintf(tw, "banned users\t%d\n", banned)
+	fmt.Fprintf(tw, "active sessions\t%s\n", sessions)
+	fmt.Fprintf(tw, "posts\t%d\n", posts)
+	fmt.Fprintf(tw, "comments\t%d\n", comments)
+
+	return tw.Flush()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/user"
+)
+
+const defaultListLimit = 50
+
+func listUsers(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("users list", flag.ContinueOnError)
+	offset := fs.Int("offset", 0, "number of users to skip")
+	limit := fs.Int("limit", defaultListLimit, "maximum number of users to list")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	users, err := a.userRepo.Users(ctx, *offset, *limit)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSERNAME\tCREATED\tKARMA\tBANNED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%t\n",
+			u.ID, u.Username, u.Created.Format(time.RFC3339), u.Karma.Post+u.Karma.Comment, u.Banned)
+	}
+
+	return tw.Flush()
+}
+
+func banUser(ctx context.Context, a *app, args []string) error {
+	return setBanned(ctx, a, args, true)
+}
+
+func unbanUser(ctx context.Context, a *app, args []string) error {
+	return setBanned(ctx, a, args, false)
+}
+
+func setBanned(ctx context.Context, a *app, args []string, banned bool) error {
+	username, err := oneArg(args, "username")
+	if err != nil {
+		return err
+	}
+
+	u, err := a.userRepo.UserByName(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	err = a.userRepo.SetBanned(ctx, u.ID, banned)
+	if err != nil {
+		return err
+	}
+
+	action := audit.ActionUserUnban
+	if banned {
+		action = audit.ActionUserBan
+	}
+	a.record(ctx, action, audit.TargetUser, u.ID, nil, nil)
+
+	if !banned {
+		fmt.Fprintf(a.out, "unbanned %s\n", username)
+		return nil
+	}
+
+	revoked, err := a.revokeSessions(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "banned %s, revoked %d sessions\n", username, revoked)
+
+	return nil
+}
+
+func deleteUser(ctx context.Context, a *app, args []string) error {
+	username, err := oneArg(args, "username")
+	if err != nil {
+		return err
+	}
+
+	u, err := a.userRepo.UserByName(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	err = a.userRepo.DeleteUser(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+
+	a.record(ctx, audit.ActionUserDelete, audit.TargetUser, u.ID, u.Profile(), nil)
+
+	fmt.Fprintf(a.out, "deleted %s\n", username)
+
+	return nil
+}
+
+func resetPassword(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("users reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "new password; a random one is generated if empty")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	username, err := oneArg(fs.Args(), "username")
+	if err != nil {
+		return err
+	}
+
+	u, err := a.userRepo.UserByName(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	newPassword := *password
+	if len(newPassword) == 0 {
+		newPassword, err = randomPassword()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = a.userRepo.SetPassword(ctx, u.ID, newPassword)
+	if err != nil {
+		return err
+	}
+
+	a.record(ctx, audit.ActionPasswordReset, audit.TargetUser, u.ID, nil, nil)
+
+	revoked, err := a.revokeSessions(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "password of %s reset, revoked %d sessions\n", username, revoked)
+	if len(*password) == 0 {
+		fmt.Fprintf(a.out, "new password: %s\n", newPassword)
+	}
+
+	return nil
+}
+
+func setEmail(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("users set-email", flag.ContinueOnError)
+	email := fs.String("email", "", "email address; the address is removed if empty")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	username, err := oneArg(fs.Args(), "username")
+	if err != nil {
+		return err
+	}
+
+	if len(*email) != 0 && !user.ValidEmail(*email) {
+		return fmt.Errorf("invalid email address %q", *email)
+	}
+
+	u, err := a.userRepo.UserByName(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	// Addresses set by administrators need no verification.
+	err = a.userRepo.SetEmail(ctx, u.ID, *email, true)
+	if err != nil {
+		return err
+	}
+
+	a.record(ctx, audit.ActionEmailChange, audit.TargetUser, u.ID, map[string]string{"email": u.Email}, map[string]string{"email": *email})
+
+	fmt.Fprintf(a.out, "email of %s set to %q\n", username, *email)
+
+	return nil
+}
+
+func revokeSessions(ctx context.Context, a *app, args []string) error {
+	username, err := oneArg(args, "username")
+	if err != nil {
+		return err
+	}
+
+	u, err := a.userRepo.UserByName(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := a.revokeSessions(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+
+	a.record(ctx, audit.ActionSessionRevoke, audit.TargetUser, u.ID, nil, nil)
+
+	fmt.Fprintf(a.out, "revoked %d sessions of %s\n", revoked, username)
+
+	return nil
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 12)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -2,24 +2,40 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/broker"
 	"github.com/teatah/rclone/pkg/config"
 	"github.com/teatah/rclone/pkg/databases/mongodb"
 	"github.com/teatah/rclone/pkg/databases/postgres"
+	"github.com/teatah/rclone/pkg/gql"
+	"github.com/teatah/rclone/pkg/grpcapi"
 	"github.com/teatah/rclone/pkg/handlers"
+	"github.com/teatah/rclone/pkg/mail"
+	"github.com/teatah/rclone/pkg/message"
 	mdw "github.com/teatah/rclone/pkg/middleware"
+	"github.com/teatah/rclone/pkg/migrate"
+	"github.com/teatah/rclone/pkg/notification"
+	"github.com/teatah/rclone/pkg/openapi"
+	"github.com/teatah/rclone/pkg/outbox"
 	"github.com/teatah/rclone/pkg/post"
 	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/token"
 	"github.com/teatah/rclone/pkg/user"
+	"github.com/teatah/rclone/pkg/webhook"
 	"go.uber.org/zap"
 )
 
@@ -42,12 +58,27 @@ func main() {
 	}()
 	sugar := logger.Sugar()
 
-	config, err := config.LoadConfig()
+	config, _, err := config.Load("redditclone", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		sugar.Errorf("failed to load config: %s", err)
 		return
 	}
 
+	tokenKeys, err := token.LoadKeySet(config.Auth.JWTSigningKey, config.Auth.JWTSecret, config.Auth.JWTKeys)
+	if err != nil {
+		sugar.Errorf("failed to load token keys: %s", err)
+		return
+	}
+	token.Configure(tokenKeys, token.Settings{
+		TTL:       config.Auth.TokenTTL,
+		Issuer:    config.Auth.Issuer,
+		Audience:  config.Auth.Audience,
+		ClockSkew: config.Auth.ClockSkew,
+	})
+
 	ctx := context.Background()
 	pgPool, err := postgres.ConnectPool(ctx, config)
 	if err != nil {
@@ -70,31 +101,132 @@ func main() {
 	}()
 	fmt.Println("connected to MongoDB!")
 
-	postsCollection := mongoClient.Database(config.MongoDB.Name).Collection("posts")
-	err = mongodb.SetIndex(ctx, postsCollection, "votes.user")
+	mongoDB := mongoClient.Database(config.MongoDB.Name)
+
+	if config.MigrateOnStart {
+		err = migrate.UpAll(ctx, pgPool, mongoDB, sugar)
+		if err != nil {
+			sugar.Errorf("failed to migrate: %s", err)
+			return
+		}
+	}
+
+	postsCollection := mongoDB.Collection("posts")
+	viewsCollection := mongoDB.Collection("views")
+	trashCollection := mongoDB.Collection("deleted_posts")
+	notificationsCollection := mongoDB.Collection("notifications")
+	notificationSettingsCollection := mongoDB.Collection("notification_settings")
+
+	spec, err := openapi.Load()
 	if err != nil {
-		sugar.Errorf("failed to create mongo index: %s", err)
+		sugar.Errorf("failed to load openapi spec: %s", err)
 		return
 	}
 
 	r := mux.NewRouter()
 	http.NewServeMux()
 
+	if config.IsDevelopment() {
+		r.Use(openapi.ValidationMiddleware(spec, sugar))
+	}
+
 	staticHandler := http.StripPrefix(
 		"/static/",
 		http.FileServer(http.Dir("./web/static")),
 	)
 	r.PathPrefix("/static/").Handler(staticHandler)
 
+	eventBroker := broker.NewBroker(config.Stream.BufferSize)
+
+	webhooksCollection := mongoDB.Collection("webhooks")
+	deliveriesCollection := mongoDB.Collection("webhook_deliveries")
+
+	webhookRepo := webhook.NewWebhookDBRepo(webhooksCollection, deliveriesCollection)
+	webhookDispatcher := webhook.NewDispatcher(webhookRepo, &http.Client{Timeout: config.Webhooks.Timeout}, sugar)
+	webhookDispatcher.MaxAttempts = config.Webhooks.MaxAttempts
+	webhookDispatcher.Backoff = config.Webhooks.Backoff
+	webhookDispatcher.Workers = config.Webhooks.Workers
+
 	userRepo := user.NewUserDBRepo(pgPool)
-	postRepo := post.NewPostDBRepo(postsCollection)
+	userRepo.Policy.UsernameMinLen = config.Users.UsernameMinLength
+	userRepo.Policy.UsernameMaxLen = config.Users.UsernameMaxLength
+	userRepo.Policy.UsernamePattern = regexp.MustCompile(config.Users.UsernamePattern)
+	userRepo.Policy.PasswordMinLen = config.Users.PasswordMinLength
+	userRepo.Policy.RequireVerifiedEmail = config.Users.RequireVerifiedEmail
+	userRepo.Policy.Reserve(config.Users.ReservedUsernames...)
+	if len(config.Users.PasswordBlocklist) != 0 {
+		passwords, err := user.LoadPasswordList(config.Users.PasswordBlocklist)
+		if err != nil {
+			sugar.Errorf("failed to load password blocklist: %s", err)
+			return
+		}
+		userRepo.Policy.BlockPasswords(passwords...)
+	}
+	postRepo := post.NewPostDBRepo(
+		postsCollection,
+		viewsCollection,
+		trashCollection,
+		userRepo,
+		post.Publishers{eventBroker, webhookDispatcher},
+	)
+	postRepo.Logger = sugar
+
+	notificationRepo := notification.NewNotificationDBRepo(notificationsCollection, notificationSettingsCollection)
+	notifier := notification.NewNotifier(notificationRepo, userRepo)
+
+	outboxDispatcher := outbox.NewDispatcher(sugar, outbox.NewPGStore(pgPool), outbox.NewMongoStore(postsCollection))
+	outboxDispatcher.PollInterval = config.Outbox.PollInterval
+	outboxDispatcher.BatchSize = config.Outbox.BatchSize
+	outboxDispatcher.MaxBackoff = config.Outbox.MaxBackoff
+	outboxDispatcher.MaxAttempts = config.Outbox.MaxAttempts
+	notificationSubscriber := &notification.Subscriber{
+		Notifier: notifier,
+		PostRepo: postRepo,
+	}
+	notificationSubscriber.Register(outboxDispatcher)
 
-	sm := session.NewDBSessionManager(pgPool)
+	var sessionStore session.Store = session.NewPGStore(pgPool)
+	if config.Sessions.InMemory() {
+		sessionStore = session.NewMemoryStore()
+	}
+	if config.Sessions.Cache {
+		sessionStore = session.NewCachedStore(sessionStore, config.Sessions.CacheSize, config.Sessions.CacheTTL)
+	}
+
+	sm := session.NewDBSessionManager(pgPool, sessionStore)
+	sm.RefreshTTL = config.Auth.RefreshTTL
+
+	auditRepo := audit.NewAuditDBRepo(pgPool)
+	auditRecorder := audit.NewRecorder(auditRepo, sugar)
+
+	var mailer mail.Mailer
+	if config.Mail.UsesSMTP() {
+		smtpMailer := mail.NewSMTPMailer(config.Mail.Host, config.Mail.Port, config.Mail.Username, config.Mail.Password, config.Mail.From)
+		smtpMailer.Timeout = config.Mail.Timeout
+		mailer = smtpMailer
+	} else {
+		memoryMailer := mail.NewMemoryMailer()
+		if config.IsDevelopment() {
+			memoryMailer.Logger = sugar
+		}
+		mailer = memoryMailer
+	}
+
+	emailVerifier := &handlers.EmailVerifier{
+		UserRepo:       userRepo,
+		Mailer:         mailer,
+		TTL:            config.Users.EmailVerificationTTL,
+		URL:            config.Users.EmailVerificationURL,
+		ResendInterval: config.Users.EmailResendInterval,
+	}
 
 	userHandler := handlers.UserHandler{
 		SessionManager: sm,
 		Logger:         sugar,
 		UserRepo:       userRepo,
+		PostRepo:       postRepo,
+		Audit:          auditRecorder,
+		EmailVerifier:  emailVerifier,
 	}
 
 	ph := handlers.PostHandler{
@@ -102,16 +234,122 @@ func main() {
 		Logger:         sugar,
 		PostRepo:       postRepo,
 		UserRepo:       userRepo,
+		Audit:          auditRecorder,
+	}
+
+	schema, err := gql.NewSchema(postRepo, userRepo, auditRecorder, sugar)
+	if err != nil {
+		sugar.Errorf("failed to build graphql schema: %s", err)
+		return
+	}
+
+	gh := &handlers.GraphQLHandler{
+		SessionManager: sm,
+		Logger:         sugar,
+		Schema:         schema,
+		UserRepo:       userRepo,
+	}
+
+	sessh := handlers.SessionHandler{
+		Logger:         sugar,
+		SessionManager: sm,
+		UserRepo:       userRepo,
+		Audit:          auditRecorder,
+	}
+
+	pwh := handlers.PasswordHandler{
+		Logger:         sugar,
+		UserRepo:       userRepo,
+		SessionManager: sm,
+		Mailer:         mailer,
+		Audit:          auditRecorder,
+		ResetTTL:       config.Users.PasswordResetTTL,
+		ResetURL:       config.Users.PasswordResetURL,
+	}
+
+	eh := handlers.EmailHandler{
+		Logger:   sugar,
+		UserRepo: userRepo,
+		Verifier: emailVerifier,
+		Audit:    auditRecorder,
+	}
+
+	jh := handlers.JWKSHandler{
+		Logger: sugar,
+		KeySet: tokenKeys,
+	}
+
+	fh := handlers.FeedHandler{
+		Logger:   sugar,
+		PostRepo: postRepo,
+	}
+
+	mh := handlers.MessageHandler{
+		Logger:      sugar,
+		MessageRepo: message.NewMessageDBRepo(pgPool),
+		UserRepo:    userRepo,
+	}
+
+	wh := handlers.WebhookHandler{
+		SessionManager: sm,
+		Logger:         sugar,
+		WebhookRepo:    webhookRepo,
+		Audit:          auditRecorder,
+	}
+
+	ah := handlers.AuditHandler{
+		Logger:    sugar,
+		AuditRepo: auditRepo,
+	}
+
+	sh := handlers.StreamHandler{
+		Logger:    sugar,
+		Broker:    eventBroker,
+		Heartbeat: config.Stream.Heartbeat,
+	}
+
+	nh := handlers.NotificationHandler{
+		Logger:           sugar,
+		NotificationRepo: notificationRepo,
 	}
 
 	r.HandleFunc("/", index).Methods("GET")
+	r.HandleFunc("/rss", fh.AllPostsRSS).Methods(http.MethodGet)
+	r.HandleFunc("/atom", fh.AllPostsAtom).Methods(http.MethodGet)
+	r.HandleFunc("/rss/a/{category}", fh.CategoryRSS).Methods(http.MethodGet)
+	r.HandleFunc("/atom/a/{category}", fh.CategoryAtom).Methods(http.MethodGet)
+	r.HandleFunc("/rss/u/{username}", fh.UserRSS).Methods(http.MethodGet)
+	r.HandleFunc("/atom/u/{username}", fh.UserAtom).Methods(http.MethodGet)
+	r.Handle("/api/openapi.json", spec).Methods(http.MethodGet)
+	r.HandleFunc("/.well-known/jwks.json", jh.Keys).Methods(http.MethodGet)
+	r.Handle("/graphql", gh).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/api/register", userHandler.Register).Methods(http.MethodPost)
 	r.HandleFunc("/api/login", userHandler.Login).Methods(http.MethodPost)
+	r.HandleFunc("/api/token/refresh", userHandler.Refresh).Methods(http.MethodPost)
+	r.HandleFunc("/api/password/reset", pwh.RequestReset).Methods(http.MethodPost)
+	r.HandleFunc("/api/password/reset/confirm", pwh.ConfirmReset).Methods(http.MethodPost)
+	r.HandleFunc("/api/email/verify", eh.Verify).Methods(http.MethodGet)
 	r.HandleFunc("/api/posts/", ph.Posts).Methods(http.MethodGet)
 	r.HandleFunc("/api/post/{postID}", ph.GetPost).Methods(http.MethodGet)
+	r.HandleFunc("/api/post/{postID}/preview", ph.PreviewPost).Methods(http.MethodGet)
 	r.HandleFunc("/api/user/{username}", ph.PostsByUser).Methods(http.MethodGet)
+	r.HandleFunc("/api/user/{username}/karma", userHandler.Karma).Methods(http.MethodGet)
+	r.HandleFunc("/api/user/{username}/profile", userHandler.Profile).Methods(http.MethodGet)
+	r.HandleFunc("/api/user/{username}/comments", ph.CommentsByUser).Methods(http.MethodGet)
 	r.HandleFunc("/api/posts/{category}", ph.PostsByCategory).Methods(http.MethodGet)
 
+	logoutHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(sessh.Logout))
+	r.Handle("/api/logout", logoutHandler).Methods(http.MethodPost)
+
+	sessionsHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(sessh.Sessions))
+	r.Handle("/api/sessions", sessionsHandler).Methods(http.MethodGet)
+
+	revokeOtherSessionsHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(sessh.RevokeOthers))
+	r.Handle("/api/sessions", revokeOtherSessionsHandler).Methods(http.MethodDelete)
+
+	revokeSessionHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(sessh.Revoke))
+	r.Handle("/api/sessions/{sessionID}", revokeSessionHandler).Methods(http.MethodDelete)
+
 	createPostHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(ph.CreatePost))
 	r.Handle("/api/posts", createPostHandler).Methods(http.MethodPost)
 
@@ -133,17 +371,110 @@ func main() {
 	unvoteHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(ph.Unvote))
 	r.Handle("/api/post/{postID}/unvote", unvoteHandler).Methods(http.MethodGet)
 
-	mux := mdw.LogMiddleware(sugar, r)
+	updateProfileHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(userHandler.UpdateProfile))
+	r.Handle("/api/user/me", updateProfileHandler).Methods(http.MethodPatch)
+
+	changePasswordHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(pwh.ChangePassword))
+	r.Handle("/api/user/me/password", changePasswordHandler).Methods(http.MethodPut)
+
+	getEmailHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(eh.GetEmail))
+	r.Handle("/api/user/me/email", getEmailHandler).Methods(http.MethodGet)
+
+	setEmailHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(eh.SetEmail))
+	r.Handle("/api/user/me/email", setEmailHandler).Methods(http.MethodPut)
+
+	resendVerificationHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(eh.ResendVerification))
+	r.Handle("/api/user/me/email/verification", resendVerificationHandler).Methods(http.MethodPost)
+
+	commentUpvoteHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(ph.CommentUpvote))
+	r.Handle("/api/post/{postID}/{commentID}/upvote", commentUpvoteHandler).Methods(http.MethodGet)
+
+	commentDownvoteHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(ph.CommentDownvote))
+	r.Handle("/api/post/{postID}/{commentID}/downvote", commentDownvoteHandler).Methods(http.MethodGet)
+
+	commentUnvoteHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(ph.CommentUnvote))
+	r.Handle("/api/post/{postID}/{commentID}/unvote", commentUnvoteHandler).Methods(http.MethodGet)
+
+	notificationsHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(nh.Notifications))
+	r.Handle("/api/notifications", notificationsHandler).Methods(http.MethodGet)
+
+	unreadCountHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(nh.UnreadCount))
+	r.Handle("/api/notifications/unread", unreadCountHandler).Methods(http.MethodGet)
+
+	markAllReadHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(nh.MarkAllRead))
+	r.Handle("/api/notifications/read", markAllReadHandler).Methods(http.MethodPost)
+
+	markReadHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(nh.MarkRead))
+	r.Handle("/api/notifications/{notificationID}/read", markReadHandler).Methods(http.MethodPost)
+
+	notificationSettingsHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(nh.Settings))
+	r.Handle("/api/notifications/settings", notificationSettingsHandler).Methods(http.MethodGet)
+
+	updateNotificationSettingsHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(nh.UpdateSettings))
+	r.Handle("/api/notifications/settings", updateNotificationSettingsHandler).Methods(http.MethodPut)
+
+	postEventsHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(sh.PostEvents))
+	r.Handle("/api/post/{postID}/events", postEventsHandler).Methods(http.MethodGet)
+
+	categoryEventsHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(sh.CategoryEvents))
+	r.Handle("/api/posts/{category}/events", categoryEventsHandler).Methods(http.MethodGet)
+
+	conversationsHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(mh.Conversations))
+	r.Handle("/api/conversations", conversationsHandler).Methods(http.MethodGet)
+
+	messagesHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(mh.Messages))
+	r.Handle("/api/conversations/{conversationID}", messagesHandler).Methods(http.MethodGet)
+
+	markConversationReadHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(mh.MarkRead))
+	r.Handle("/api/conversations/{conversationID}/read", markConversationReadHandler).Methods(http.MethodPost)
+
+	unreadMessagesHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(mh.UnreadCount))
+	r.Handle("/api/messages/unread", unreadMessagesHandler).Methods(http.MethodGet)
+
+	sendMessageHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(mh.Send))
+	r.Handle("/api/messages/{username}", sendMessageHandler).Methods(http.MethodPost)
+
+	blockedHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(mh.Blocked))
+	r.Handle("/api/blocks", blockedHandler).Methods(http.MethodGet)
+
+	blockHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(mh.Block))
+	r.Handle("/api/blocks/{username}", blockHandler).Methods(http.MethodPut)
+
+	unblockHandler := mdw.AuthMiddleware(sm, sugar, http.HandlerFunc(mh.Unblock))
+	r.Handle("/api/blocks/{username}", unblockHandler).Methods(http.MethodDelete)
+
+	adminOnly := func(h http.HandlerFunc) http.Handler {
+		return mdw.AuthMiddleware(sm, sugar, mdw.AdminMiddleware(config.Admins, userRepo, sugar, h))
+	}
+
+	r.Handle("/api/admin/webhooks", adminOnly(wh.Webhooks)).Methods(http.MethodGet)
+	r.Handle("/api/admin/webhooks", adminOnly(wh.Create)).Methods(http.MethodPost)
+	r.Handle("/api/admin/webhooks/{webhookID}", adminOnly(wh.Webhook)).Methods(http.MethodGet)
+	r.Handle("/api/admin/webhooks/{webhookID}", adminOnly(wh.Delete)).Methods(http.MethodDelete)
+	r.Handle("/api/admin/webhooks/{webhookID}/deliveries", adminOnly(wh.Deliveries)).Methods(http.MethodGet)
+	r.Handle("/api/admin/audit", adminOnly(ah.Entries)).Methods(http.MethodGet)
+
+	mux := mdw.ClientMiddleware(r)
+	mux = mdw.LogMiddleware(sugar, mux)
 	mux = mdw.PanicMiddleware(sugar, mux)
 
-	addr := ":8080"
+	addr := config.HTTP.Addr
 
-	ticker := time.NewTicker(time.Minute * 1 / 2)
+	ticker := time.NewTicker(config.Jobs.SessionCleanupInterval)
 	defer ticker.Stop()
 
+	karmaTicker := time.NewTicker(config.Jobs.KarmaInterval)
+	defer karmaTicker.Stop()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	bgCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+
+	go webhookDispatcher.Run(bgCtx)
+	go outboxDispatcher.Run(bgCtx)
+
 	go func() {
 		for {
 			select {
@@ -152,6 +483,16 @@ func main() {
 				if err != nil {
 					sugar.Errorf("failed to remove expired sessions: %v", err)
 				}
+
+				err = userRepo.RemoveExpiredPasswordResets(ctx)
+				if err != nil {
+					sugar.Errorf("failed to remove expired password resets: %v", err)
+				}
+			case <-karmaTicker.C:
+				err := post.ReconcileKarma(ctx, postRepo, userRepo)
+				if err != nil {
+					sugar.Errorf("failed to reconcile karma: %v", err)
+				}
 			case <-quit:
 				return
 			}
@@ -160,6 +501,23 @@ func main() {
 
 	server := &http.Server{Addr: addr, Handler: mux}
 
+	grpcServer := grpcapi.NewServer(sm, postRepo, userRepo, auditRecorder, sugar)
+
+	grpcListener, err := net.Listen("tcp", config.GRPC.Addr)
+	if err != nil {
+		sugar.Errorf("failed to listen on %s: %s", config.GRPC.Addr, err)
+		return
+	}
+
+	go func() {
+		sugar.Infof("starting grpc server at port %s", config.GRPC.Addr)
+
+		err := grpcServer.Serve(grpcListener)
+		if err != nil {
+			sugar.Errorf("failed to run grpc server: %s", err)
+		}
+	}()
+
 	go func() {
 		sugar.Infof("starting server at port %s", addr)
 
@@ -171,10 +529,12 @@ func main() {
 
 	<-quit
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, config.HTTP.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
 		sugar.Errorf("server shutdown failed: %+v", err)
 	}
+
+	grpcServer.GracefulStop()
 }
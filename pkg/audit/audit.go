@@ -0,0 +1,93 @@
+// Package audit keeps an append-only log of security- and moderation-relevant
+// actions.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	ActionRegister             = "register"
+	ActionLogin                = "login"
+	ActionLoginFailed          = "login_failed"
+	ActionPostDelete           = "post_delete"
+	ActionCommentDelete        = "comment_delete"
+	ActionWebhookCreate        = "webhook_create"
+	ActionWebhookDelete        = "webhook_delete"
+	ActionUserBan              = "user_ban"
+	ActionUserUnban            = "user_unban"
+	ActionUserDelete           = "user_delete"
+	ActionPasswordReset        = "password_reset"
+	ActionSessionRevoke        = "session_revoke"
+	ActionPostRestore          = "post_restore"
+	ActionRefreshReuse         = "refresh_token_reuse"
+	ActionLogout               = "logout"
+	ActionPasswordChange       = "password_change"
+	ActionPasswordResetRequest = "password_reset_request"
+	ActionEmailChange          = "email_change"
+	ActionEmailVerify          = "email_verify"
+)
+
+const (
+	TargetUser    = "user"
+	TargetPost    = "post"
+	TargetComment = "comment"
+	TargetWebhook = "webhook"
+	TargetSession = "session"
+)
+
+// Entry is one audited action. Before and After hold JSON snapshots of the
+// target around the action, when they apply.
+type Entry struct {
+	ID         int64           `json:"id"`
+	ActorID    string          `json:"actorId,omitempty"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   string          `json:"targetId"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"userAgent"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Created    time.Time       `json:"created"`
+}
+
+// Filter selects audit entries. Empty fields match everything.
+type Filter struct {
+	Actor      string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+}
+
+type AuditRepo interface {
+	Add(ctx context.Context, e *Entry) error
+	Entries(ctx context.Context, f *Filter, offset int, limit int) ([]*Entry, error)
+}
+
+func NewEntry(action string, targetType string, targetID string) *Entry {
+	return &Entry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Created:    time.Now().UTC(),
+	}
+}
+
+// Snapshot encodes v for Entry.Before or Entry.After. It returns nil for nil
+// values and values that can't be encoded.
+func Snapshot(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
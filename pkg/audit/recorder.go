@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type clientCtxKey struct{}
+
+// Client describes where a request came from.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientCtxKey{}, c)
+}
+
+func ClientFromContext(ctx context.Context) *Client {
+	c, ok := ctx.Value(clientCtxKey{}).(*Client)
+	if !ok {
+		return &Client{}
+	}
+
+	return c
+}
+
+// Recorder stores audit entries on behalf of the API handlers.
+type Recorder struct {
+	repo   AuditRepo
+	logger *zap.SugaredLogger
+}
+
+func NewRecorder(repo AuditRepo, logger *zap.SugaredLogger) *Recorder {
+	return &Recorder{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Record fills in the client of the request from ctx and stores the entry.
+// The audited action has already happened, so failures are only logged.
+func (rec *Recorder) Record(ctx context.Context, e *Entry) {
+	client := ClientFromContext(ctx)
+	e.IP = client.IP
+	e.UserAgent = client.UserAgent
+
+	err := rec.repo.Add(ctx, e)
+	if err != nil {
+		rec.logger.Errorf("failed to record %s audit entry for %s %s: %s", e.Action, e.TargetType, e.TargetID, err)
+	}
+}
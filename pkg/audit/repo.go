@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditDBRepo struct {
+	pgPool *pgxpool.Pool
+}
+
+func NewAuditDBRepo(pgPool *pgxpool.Pool) *AuditDBRepo {
+	return &AuditDBRepo{
+		pgPool: pgPool,
+	}
+}
+
+func (ar *AuditDBRepo) Add(ctx context.Context, e *Entry) error {
+	return ar.pgPool.QueryRow(
+		ctx,
+		`INSERT INTO audit_log
+			(actor_id, actor, action, target_type, target_id, ip, user_agent, before, after, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
+		e.ActorID,
+		e.Actor,
+		e.Action,
+		e.TargetType,
+		e.TargetID,
+		e.IP,
+		e.UserAgent,
+		nullableJSON(e.Before),
+		nullableJSON(e.After),
+		e.Created,
+	).Scan(&e.ID)
+}
+
+// Entries returns the entries matching f, newest first.
+func (ar *AuditDBRepo) Entries(ctx context.Context, f *Filter, offset int, limit int) ([]*Entry, error) {
+	var conds []string
+	var args []any
+
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if len(f.Actor) != 0 {
+		addCond("actor = $%d", f.Actor)
+	}
+	if len(f.TargetType) != 0 {
+		addCond("target_type = $%d", f.TargetType)
+	}
+	if len(f.TargetID) != 0 {
+		addCond("target_id = $%d", f.TargetID)
+	}
+	if !f.From.IsZero() {
+		addCond("created >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		addCond("created < $%d", f.To)
+	}
+
+	query := `SELECT id, actor_id, actor, action, target_type, target_id, ip, user_agent,
+		before, after, created
+		FROM audit_log`
+	if len(conds) != 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY created DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := ar.pgPool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*Entry, 0, limit)
+	for rows.Next() {
+		e := &Entry{}
+		var before, after []byte
+		err = rows.Scan(
+			&e.ID,
+			&e.ActorID,
+			&e.Actor,
+			&e.Action,
+			&e.TargetType,
+			&e.TargetID,
+			&e.IP,
+			&e.UserAgent,
+			&before,
+			&after,
+			&e.Created,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Before = before
+		e.After = after
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func nullableJSON(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return string(data)
+}
@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/teatah/rclone/pkg/post"
+)
+
+// DefaultBufferSize is the number of events a subscriber may fall behind
+// before it is disconnected.
+const DefaultBufferSize = 32
+
+func PostTopic(postID string) string {
+	return "post:" + postID
+}
+
+func CategoryTopic(category string) string {
+	return "category:" + category
+}
+
+type Subscription struct {
+	topic  string
+	events chan *post.Event
+}
+
+// Events returns the channel of events for the subscription. The channel is
+// closed when the subscriber is too slow to keep up or unsubscribes.
+func (s *Subscription) Events() <-chan *post.Event {
+	return s.events
+}
+
+// Broker fans out post events to the subscribers of the post and of its
+// category.
+type Broker struct {
+	mu         *sync.Mutex
+	subs       map[string]map[*Subscription]struct{}
+	bufferSize int
+}
+
+func NewBroker(bufferSize int) *Broker {
+	return &Broker{
+		mu:         &sync.Mutex{},
+		subs:       make(map[string]map[*Subscription]struct{}),
+		bufferSize: bufferSize,
+	}
+}
+
+func (b *Broker) Subscribe(topic string) *Subscription {
+	sub := &Subscription{
+		topic:  topic,
+		events: make(chan *post.Event, b.bufferSize),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topicSubs, ok := b.subs[topic]
+	if !ok {
+		topicSubs = make(map[*Subscription]struct{})
+		b.subs[topic] = topicSubs
+	}
+	topicSubs[sub] = struct{}{}
+
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.removeLocked(sub)
+}
+
+// Publish delivers the event without blocking. Subscribers whose buffer is
+// full are dropped.
+func (b *Broker) Publish(e *post.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topics := []string{PostTopic(e.Post.ID), CategoryTopic(e.Post.Category)}
+	for _, topic := range topics {
+		for sub := range b.subs[topic] {
+			select {
+			case sub.events <- e:
+			default:
+				b.removeLocked(sub)
+			}
+		}
+	}
+}
+
+func (b *Broker) removeLocked(sub *Subscription) {
+	topicSubs, ok := b.subs[sub.topic]
+	if !ok {
+		return
+	}
+
+	if _, ok := topicSubs[sub]; !ok {
+		return
+	}
+
+	delete(topicSubs, sub)
+	close(sub.events)
+
+	if len(topicSubs) == 0 {
+		delete(b.subs, sub.topic)
+	}
+}
@@ -1,51 +1,315 @@
+// Package config builds the server configuration from several layers. Each
+// layer overrides the one before it:
+//
+//  1. the defaults returned by Default
+//  2. a YAML or TOML file named by -config or CONFIG_FILE
+//  3. environment variables, including those set in an optional .env file
+//  4. command-line flags
+//
+// Every setting has a dotted key, such as postgres.host. The key is used in
+// the config file and as the flag name, and most settings also have an
+// environment variable.
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+const (
+	SessionStorePostgres = "postgres"
+	SessionStoreMemory   = "memory"
+)
+
+const (
+	MailDriverSMTP   = "smtp"
+	MailDriverMemory = "memory"
+)
+
+// FileEnv names the environment variable that points to the config file when
+// the -config flag is not given.
+const (
+	FileEnv  = "CONFIG_FILE"
+	fileFlag = "config"
+)
+
 type Config struct {
-	PostgresDB DBConfig
-	MongoDB    DBConfig
+	Env            string        `key:"env" env:"APP_ENV" usage:"environment, development or production"`
+	Admins         []string      `key:"admins" env:"ADMIN_USERS" usage:"comma-separated usernames allowed to use the admin API"`
+	MigrateOnStart bool          `key:"migrate_on_start" env:"MIGRATE_ON_START" usage:"apply pending database migrations on start"`
+	HTTP           HTTPConfig    `key:"http" env:"HTTP_"`
+	GRPC           GRPCConfig    `key:"grpc" env:"GRPC_"`
+	Auth           AuthConfig    `key:"auth"`
+	Sessions       SessionConfig `key:"sessions" env:"SESSION_"`
+	Users          UsersConfig   `key:"users"`
+	Mail           MailConfig    `key:"mail" env:"MAIL_"`
+	PostgresDB     DBConfig      `key:"postgres" env:"PG_"`
+	MongoDB        DBConfig      `key:"mongo" env:"MONGO_"`
+	Jobs           JobsConfig    `key:"jobs"`
+	Stream         StreamConfig  `key:"stream" env:"STREAM_"`
+	Outbox         OutboxConfig  `key:"outbox" env:"OUTBOX_"`
+	Webhooks       WebhookConfig `key:"webhooks" env:"WEBHOOK_"`
+}
+
+type HTTPConfig struct {
+	Addr            string        `key:"addr" env:"ADDR" required:"true" usage:"address the HTTP server listens on"`
+	ShutdownTimeout time.Duration `key:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"time given to open requests on shutdown"`
+}
+
+type GRPCConfig struct {
+	Addr string `key:"addr" env:"ADDR" required:"true" usage:"address the gRPC server listens on"`
+}
+
+type AuthConfig struct {
+	JWTSecret     string        `key:"jwt_secret" env:"JWT_SECRET" usage:"shared secret that signs and verifies HS256 access tokens"`
+	JWTKeys       []string      `key:"jwt_keys" env:"JWT_KEYS" usage:"comma-separated PEM files of RSA or Ed25519 keys, identified by the file name up to its first dot"`
+	JWTSigningKey string        `key:"jwt_signing_key" env:"JWT_SIGNING_KEY" usage:"id of the key that signs new tokens, the first private key file by default"`
+	TokenTTL      time.Duration `key:"token_ttl" env:"TOKEN_TTL" usage:"lifetime of access tokens and their sessions"`
+	RefreshTTL    time.Duration `key:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL" usage:"lifetime of a refresh token, renewed on every refresh"`
+	Issuer        string        `key:"issuer" env:"TOKEN_ISSUER" required:"true" usage:"iss claim set on access tokens and required when verifying them"`
+	Audience      string        `key:"audience" env:"TOKEN_AUDIENCE" required:"true" usage:"aud claim set on access tokens and required when verifying them"`
+	ClockSkew     time.Duration `key:"clock_skew" env:"TOKEN_CLOCK_SKEW" usage:"tolerance for clock differences when checking exp, nbf and iat"`
+}
+
+type SessionConfig struct {
+	Store     string        `key:"store" env:"STORE" usage:"where sessions are kept, postgres or memory"`
+	Cache     bool          `key:"cache" env:"CACHE" usage:"cache recently used sessions in memory in front of the store"`
+	CacheSize int           `key:"cache_size" env:"CACHE_SIZE" usage:"sessions kept in the cache"`
+	CacheTTL  time.Duration `key:"cache_ttl" env:"CACHE_TTL" usage:"how long a session is cached, which bounds how late revocations by other processes are seen"`
+}
+
+type UsersConfig struct {
+	UsernameMinLength int           `key:"username_min_length" env:"USERNAME_MIN_LENGTH" usage:"shortest username accepted on registration, in characters"`
+	UsernameMaxLength int           `key:"username_max_length" env:"USERNAME_MAX_LENGTH" usage:"longest username accepted on registration, in characters, at most 55"`
+	UsernamePattern   string        `key:"username_pattern" env:"USERNAME_PATTERN" required:"true" usage:"regular expression usernames must match"`
+	ReservedUsernames []string      `key:"reserved_usernames" env:"RESERVED_USERNAMES" usage:"comma-separated usernames that cannot be registered, in addition to the built-in ones"`
+	PasswordMinLength int           `key:"password_min_length" env:"PASSWORD_MIN_LENGTH" usage:"shortest password accepted, in characters"`
+	PasswordBlocklist string        `key:"password_blocklist" env:"PASSWORD_BLOCKLIST" usage:"file of breached or common passwords to reject, one per line, in addition to the built-in list"`
+	PasswordResetTTL  time.Duration `key:"password_reset_ttl" env:"PASSWORD_RESET_TTL" usage:"how long a password reset link works"`
+	PasswordResetURL  string        `key:"password_reset_url" env:"PASSWORD_RESET_URL" required:"true" usage:"page password reset links point to, with the token in the token query parameter"`
+
+	EmailVerificationTTL time.Duration `key:"email_verification_ttl" env:"EMAIL_VERIFICATION_TTL" usage:"how long an email verification link works"`
+	EmailVerificationURL string        `key:"email_verification_url" env:"EMAIL_VERIFICATION_URL" required:"true" usage:"where email verification links point to, with the token in the token query parameter"`
+	EmailResendInterval  time.Duration `key:"email_resend_interval" env:"EMAIL_RESEND_INTERVAL" usage:"how long a user waits before another verification link is sent"`
+	RequireVerifiedEmail bool          `key:"require_verified_email" env:"REQUIRE_VERIFIED_EMAIL" usage:"only let users with a verified email address post and comment"`
+}
+
+type MailConfig struct {
+	Driver   string        `key:"driver" env:"DRIVER" usage:"how mail is sent, smtp or memory, which only logs it and needs env development"`
+	Host     string        `key:"host" env:"HOST" usage:"SMTP server host"`
+	Port     string        `key:"port" env:"PORT" usage:"SMTP server port"`
+	Username string        `key:"username" env:"USERNAME" usage:"SMTP user, no authentication when empty"`
+	Password string        `key:"password" env:"PASSWORD" usage:"SMTP password"`
+	From     string        `key:"from" env:"FROM" required:"true" usage:"sender address of outgoing mail"`
+	Timeout  time.Duration `key:"timeout" env:"TIMEOUT" usage:"timeout of sending a single message"`
+}
+
+// UsesSMTP reports whether mail is sent through an SMTP server.
+func (c MailConfig) UsesSMTP() bool {
+	return c.Driver == MailDriverSMTP
 }
 
 type DBConfig struct {
-	User     string
-	Password string
-	Name     string
-	Host     string
-	Port     string
+	User     string `key:"user" env:"USER" required:"true" usage:"database user"`
+	Password string `key:"password" env:"PASS" usage:"database password"`
+	Name     string `key:"name" env:"NAME" required:"true" usage:"database name"`
+	Host     string `key:"host" env:"HOST" required:"true" usage:"database host"`
+	Port     string `key:"port" env:"PORT" required:"true" usage:"database port"`
 }
 
-func LoadConfig() (*Config, error) {
-	err := godotenv.Load()
-	if err != nil {
-		return nil, err
-	}
+type JobsConfig struct {
+	SessionCleanupInterval time.Duration `key:"session_cleanup_interval" env:"SESSION_CLEANUP_INTERVAL" usage:"how often expired sessions are removed"`
+	KarmaInterval          time.Duration `key:"karma_interval" env:"KARMA_INTERVAL" usage:"how often user karma is recomputed"`
+}
+
+type StreamConfig struct {
+	BufferSize int           `key:"buffer_size" env:"BUFFER_SIZE" usage:"events buffered per subscriber before it is dropped"`
+	Heartbeat  time.Duration `key:"heartbeat" env:"HEARTBEAT" usage:"interval of keep-alive comments on event streams"`
+}
 
+type OutboxConfig struct {
+	PollInterval time.Duration `key:"poll_interval" env:"POLL_INTERVAL" usage:"how often pending domain events are polled"`
+	BatchSize    int           `key:"batch_size" env:"BATCH_SIZE" usage:"pending events read from each store per poll"`
+	MaxBackoff   time.Duration `key:"max_backoff" env:"MAX_BACKOFF" usage:"longest wait before retrying a failed event"`
+	MaxAttempts  int           `key:"max_attempts" env:"MAX_ATTEMPTS" usage:"deliveries of an event before it is marked failed and no longer retried"`
+}
+
+type WebhookConfig struct {
+	MaxAttempts int           `key:"max_attempts" env:"MAX_ATTEMPTS" usage:"delivery attempts before a delivery is given up"`
+	Backoff     time.Duration `key:"backoff" env:"BACKOFF" usage:"wait before the first retry, doubled after each attempt"`
+	Timeout     time.Duration `key:"timeout" env:"TIMEOUT" usage:"timeout of a single delivery request"`
+	Workers     int           `key:"workers" env:"WORKERS" usage:"deliveries sent at the same time"`
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() *Config {
 	return &Config{
-		PostgresDB: DBConfig{
-			User:     getEnv("PG_USER", ""),
-			Password: getEnv("PG_PASS", ""),
-			Name:     getEnv("PG_NAME", ""),
-			Host:     getEnv("PG_HOST", ""),
-			Port:     getEnv("PG_PORT", ""),
+		Env:            EnvProduction,
+		MigrateOnStart: true,
+		HTTP: HTTPConfig{
+			Addr:            ":8080",
+			ShutdownTimeout: 5 * time.Second,
+		},
+		GRPC: GRPCConfig{
+			Addr: ":9090",
+		},
+		Auth: AuthConfig{
+			TokenTTL:   15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+			Issuer:     "rclone",
+			Audience:   "rclone",
+			ClockSkew:  30 * time.Second,
+		},
+		Sessions: SessionConfig{
+			Store:     SessionStorePostgres,
+			CacheSize: 10000,
+			CacheTTL:  time.Minute,
+		},
+		Users: UsersConfig{
+			UsernameMinLength: 3,
+			UsernameMaxLength: 32,
+			UsernamePattern:   `^[\p{L}\p{N}_-]+$`,
+			PasswordMinLength: 8,
+			PasswordResetTTL:  time.Hour,
+			PasswordResetURL:  "http://localhost:8080/password/reset",
+
+			EmailVerificationTTL: 24 * time.Hour,
+			EmailVerificationURL: "http://localhost:8080/api/email/verify",
+			EmailResendInterval:  5 * time.Minute,
 		},
-		MongoDB: DBConfig{
-			User:     getEnv("MONGO_USER", ""),
-			Password: getEnv("MONGO_PASS", ""),
-			Name:     getEnv("MONGO_NAME", ""),
-			Host:     getEnv("MONGO_HOST", ""),
-			Port:     getEnv("MONGO_PORT", ""),
+		Mail: MailConfig{
+			Driver:  MailDriverSMTP,
+			Port:    "587",
+			From:    "rclone <noreply@localhost>",
+			Timeout: 10 * time.Second,
 		},
-	}, nil
+		Jobs: JobsConfig{
+			SessionCleanupInterval: 30 * time.Second,
+			KarmaInterval:          time.Hour,
+		},
+		Stream: StreamConfig{
+			BufferSize: 32,
+			Heartbeat:  30 * time.Second,
+		},
+		Outbox: OutboxConfig{
+			PollInterval: time.Second,
+			BatchSize:    100,
+			MaxBackoff:   5 * time.Minute,
+			MaxAttempts:  10,
+		},
+		Webhooks: WebhookConfig{
+			MaxAttempts: 6,
+			Backoff:     30 * time.Second,
+			Timeout:     10 * time.Second,
+			Workers:     4,
+		},
+	}
+}
+
+// Load builds the configuration of the program called name from all layers,
+// taking the flags from args. It returns the arguments left after the flags.
+func Load(name string, args []string) (*Config, []string, error) {
+	config := Default()
+	settings := settingsOf(config)
+
+	flagValues, rest, err := parseFlags(name, settings, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to read .env: %w", err)
+	}
+
+	path, ok := flagValues[fileFlag]
+	if !ok {
+		path = os.Getenv(FileEnv)
+	}
+	if len(path) != 0 {
+		err = applyFile(settings, path)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = applyEnv(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range settings {
+		value, ok := flagValues[s.key]
+		if !ok {
+			continue
+		}
+		err = s.set(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("flag -%s: %w", s.key, err)
+		}
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, rest, nil
+}
+
+func (c *Config) IsDevelopment() bool {
+	return c.Env == EnvDevelopment
+}
+
+// InMemory reports whether sessions are kept in the memory of the server.
+func (c SessionConfig) InMemory() bool {
+	return c.Store == SessionStoreMemory
 }
 
-func getEnv(key string, defaultValue string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// parseFlags registers a flag for every setting plus -config and returns the
+// raw values of the flags given in args.
+func parseFlags(name string, settings []*setting, args []string) (map[string]string, []string, error) {
+	values := make(map[string]string)
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	flags.Func(fileFlag, "path to a YAML or TOML config file (env "+FileEnv+")", func(value string) error {
+		values[fileFlag] = value
+		return nil
+	})
+
+	for _, s := range settings {
+		store := func(value string) error {
+			values[s.key] = value
+			return nil
+		}
+
+		usage := s.usage
+		if len(s.env) != 0 {
+			usage += " (env " + s.env + ")"
+		}
+
+		if s.isBool() {
+			flags.BoolFunc(s.key, usage, store)
+		} else {
+			flags.Func(s.key, usage, store)
+		}
 	}
-	return defaultValue
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return values, flags.Args(), nil
 }
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// applyFile reads the YAML or TOML file at path, chosen by its extension, and
+// applies the settings it contains. Unknown keys are rejected so that typos
+// do not go unnoticed.
+func applyFile(settings []*setting, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tree := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	err = flatten(tree, "", values)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	for _, s := range settings {
+		value, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		delete(values, s.key)
+
+		err = s.set(value)
+		if err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, s.key, err)
+		}
+	}
+
+	if len(values) != 0 {
+		unknown := make([]string, 0, len(values))
+		for key := range values {
+			unknown = append(unknown, key)
+		}
+		sort.Strings(unknown)
+
+		return fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// flatten turns nested tables into dotted keys with string values. Lists are
+// joined with commas like list environment variables.
+func flatten(tree map[string]any, prefix string, values map[string]string) error {
+	for key, value := range tree {
+		key = prefix + key
+
+		switch v := value.(type) {
+		case map[string]any:
+			err := flatten(v, key+".", values)
+			if err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			return fmt.Errorf("%s: missing value", key)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+
+	return nil
+}
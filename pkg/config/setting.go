@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting is a single configurable field of Config.
+type setting struct {
+	key      string
+	env      string
+	usage    string
+	required bool
+	value    reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// settingsOf lists the settings of config in field order. Nested structs
+// prefix the keys and environment variables of their fields with their own.
+func settingsOf(config *Config) []*setting {
+	return collectSettings(reflect.ValueOf(config).Elem(), "", "")
+}
+
+func collectSettings(v reflect.Value, keyPrefix string, envPrefix string) []*setting {
+	var settings []*setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := keyPrefix + field.Tag.Get("key")
+		env := field.Tag.Get("env")
+
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			settings = append(settings, collectSettings(v.Field(i), key+".", envPrefix+env)...)
+			continue
+		}
+
+		if len(env) != 0 {
+			env = envPrefix + env
+		}
+
+		settings = append(settings, &setting{
+			key:      key,
+			env:      env,
+			usage:    field.Tag.Get("usage"),
+			required: field.Tag.Get("required") == "true",
+			value:    v.Field(i),
+		})
+	}
+
+	return settings
+}
+
+func (s *setting) isBool() bool {
+	return s.value.Kind() == reflect.Bool
+}
+
+// set parses value according to the type of the setting and stores it.
+func (s *setting) set(value string) error {
+	value = strings.TrimSpace(value)
+
+	if s.value.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		s.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		s.value.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		s.value.SetInt(int64(n))
+	case reflect.Slice:
+		s.value.Set(reflect.ValueOf(splitList(value)))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+
+	return nil
+}
+
+func (s *setting) isZero() bool {
+	return s.value.IsZero() || (s.value.Kind() == reflect.Slice && s.value.Len() == 0)
+}
+
+// name describes where the setting can be given, for error messages.
+func (s *setting) name() string {
+	if len(s.env) == 0 {
+		return fmt.Sprintf("%s (flag -%s)", s.key, s.key)
+	}
+
+	return fmt.Sprintf("%s (env %s, flag -%s)", s.key, s.env, s.key)
+}
+
+func applyEnv(settings []*setting) error {
+	for _, s := range settings {
+		if len(s.env) == 0 {
+			continue
+		}
+
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+
+		err := s.set(value)
+		if err != nil {
+			return fmt.Errorf("env %s: %w", s.env, err)
+		}
+	}
+
+	return nil
+}
+
+// splitList splits a comma-separated value, skipping empty items.
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) != 0 {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+)
+
+// maxUsernameLength is the width of the username column.
+const maxUsernameLength = 55
+
+// Validate reports every invalid setting of c at once.
+func (c *Config) Validate() error {
+	var errs []error
+	for _, s := range settingsOf(c) {
+		switch {
+		case s.required && s.isZero():
+			errs = append(errs, fmt.Errorf("%s is required", s.name()))
+		case s.value.Type() == durationType && s.value.Int() <= 0:
+			errs = append(errs, fmt.Errorf("%s must be a positive duration", s.name()))
+		case s.value.Kind() == reflect.Int && s.value.Int() <= 0:
+			errs = append(errs, fmt.Errorf("%s must be positive", s.name()))
+		}
+	}
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		errs = append(errs, fmt.Errorf("env (env APP_ENV) must be %s or %s, got %q", EnvDevelopment, EnvProduction, c.Env))
+	}
+
+	if c.Sessions.Store != SessionStorePostgres && c.Sessions.Store != SessionStoreMemory {
+		errs = append(errs, fmt.Errorf("sessions.store (env SESSION_STORE) must be %s or %s, got %q", SessionStorePostgres, SessionStoreMemory, c.Sessions.Store))
+	}
+
+	if c.Users.UsernameMaxLength > maxUsernameLength {
+		errs = append(errs, fmt.Errorf("users.username_max_length must be at most %d", maxUsernameLength))
+	}
+	if c.Users.UsernameMinLength > c.Users.UsernameMaxLength {
+		errs = append(errs, errors.New("users.username_min_length must not exceed users.username_max_length"))
+	}
+	_, err := regexp.Compile(c.Users.UsernamePattern)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("users.username_pattern: %w", err))
+	}
+
+	for _, link := range []struct{ key, value string }{
+		{"users.password_reset_url", c.Users.PasswordResetURL},
+		{"users.email_verification_url", c.Users.EmailVerificationURL},
+	} {
+		u, err := url.Parse(link.value)
+		if len(link.value) != 0 && (err != nil || !u.IsAbs()) {
+			errs = append(errs, fmt.Errorf("%s must be an absolute URL, got %q", link.key, link.value))
+		}
+	}
+
+	if c.Mail.Driver != MailDriverSMTP && c.Mail.Driver != MailDriverMemory {
+		errs = append(errs, fmt.Errorf("mail.driver (env MAIL_DRIVER) must be %s or %s, got %q", MailDriverSMTP, MailDriverMemory, c.Mail.Driver))
+	}
+	if c.Mail.Driver == MailDriverMemory && !c.IsDevelopment() {
+		errs = append(errs, fmt.Errorf("mail.driver (env MAIL_DRIVER) %s sends no mail and is only allowed with env %s", MailDriverMemory, EnvDevelopment))
+	}
+	if c.Mail.UsesSMTP() && (len(c.Mail.Host) == 0 || len(c.Mail.Port) == 0) {
+		errs = append(errs, errors.New("mail.host (env MAIL_HOST) and mail.port (env MAIL_PORT) are required to send mail with smtp"))
+	}
+	_, err = mail.ParseAddress(c.Mail.From)
+	if len(c.Mail.From) != 0 && err != nil {
+		errs = append(errs, fmt.Errorf("mail.from must be an email address, got %q", c.Mail.From))
+	}
+
+	if len(c.Auth.JWTSecret) == 0 && len(c.Auth.JWTKeys) == 0 {
+		errs = append(errs, errors.New("auth.jwt_secret (env JWT_SECRET) or auth.jwt_keys (env JWT_KEYS) is required"))
+	}
+
+	for _, addr := range []struct{ key, value string }{
+		{"http.addr", c.HTTP.Addr},
+		{"grpc.addr", c.GRPC.Addr},
+	} {
+		_, _, err := net.SplitHostPort(addr.value)
+		if len(addr.value) != 0 && err != nil {
+			errs = append(errs, fmt.Errorf("%s must be a host:port address, got %q", addr.key, addr.value))
+		}
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return nil
+}
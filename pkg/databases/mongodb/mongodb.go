@@ -2,8 +2,10 @@ package mongodb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/teatah/rclone/pkg/config"
 	"go.mongodb.org/mongo-driver/bson"
@@ -31,10 +33,46 @@ func Connect(ctx context.Context, config *config.Config) (*mongo.Client, error)
 
 func SetIndex(ctx context.Context, col *mongo.Collection, field string) error {
 	indexModel := mongo.IndexModel{
-		Keys: bson.M{"votes.user": 1},
+		Keys: bson.M{field: 1},
 	}
 
 	_, err := col.Indexes().CreateOne(ctx, indexModel)
 
 	return err
 }
+
+func SetTTLIndex(ctx context.Context, col *mongo.Collection, field string, ttl time.Duration) error {
+	indexModel := mongo.IndexModel{
+		Keys:    bson.M{field: 1},
+		Options: options.Index().SetExpireAfterSeconds(int32(ttl.Seconds())),
+	}
+
+	_, err := col.Indexes().CreateOne(ctx, indexModel)
+
+	return err
+}
+
+// SetUniqueIndex creates a unique index that skips documents without the field.
+func SetUniqueIndex(ctx context.Context, col *mongo.Collection, field string) error {
+	indexModel := mongo.IndexModel{
+		Keys:    bson.M{field: 1},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	}
+
+	_, err := col.Indexes().CreateOne(ctx, indexModel)
+
+	return err
+}
+
+// DropIndex drops the index created on field by one of the Set*Index
+// functions. A missing index or collection is not an error.
+func DropIndex(ctx context.Context, col *mongo.Collection, field string) error {
+	_, err := col.Indexes().DropOne(ctx, field+"_1")
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Name == "IndexNotFound" || cmdErr.Name == "NamespaceNotFound") {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,204 @@
+package feed
+
+import (
+	"encoding/xml"
+	"sort"
+	"time"
+
+	"github.com/teatah/rclone/pkg/post"
+)
+
+// MaxItems is the number of most recent posts included in a feed.
+const MaxItems = 50
+
+type RSS struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []RSSItem `xml:"item"`
+}
+
+type RSSItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	Author      string  `xml:"author,omitempty"`
+	Category    string  `xml:"category,omitempty"`
+	GUID        RSSGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type RSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type Atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    []AtomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type AtomEntry struct {
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Link      []AtomLink   `xml:"link"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Author    AtomAuthor   `xml:"author"`
+	Category  AtomCategory `xml:"category"`
+	Content   AtomContent  `xml:"content"`
+}
+
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type AtomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Feed describes a list of posts independently of the output format.
+type Feed struct {
+	Title   string
+	Link    string
+	BaseURL string
+	Posts   []post.Post
+}
+
+// New returns a feed of the most recent posts.
+func New(title string, baseURL string, link string, posts []post.Post) *Feed {
+	sorted := make([]post.Post, len(posts))
+	copy(sorted, posts)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Created.After(sorted[j].Created)
+	})
+
+	if len(sorted) > MaxItems {
+		sorted = sorted[:MaxItems]
+	}
+
+	return &Feed{
+		Title:   title,
+		Link:    baseURL + link,
+		BaseURL: baseURL,
+		Posts:   sorted,
+	}
+}
+
+// LastModified returns the time of the latest post or comment in the feed.
+func (f *Feed) LastModified() time.Time {
+	var lastModified time.Time
+	for _, p := range f.Posts {
+		if p.Created.After(lastModified) {
+			lastModified = p.Created
+		}
+
+		for _, c := range p.Comments {
+			if c.Created.After(lastModified) {
+				lastModified = c.Created
+			}
+		}
+	}
+
+	return lastModified
+}
+
+func (f *Feed) PostLink(p *post.Post) string {
+	return f.BaseURL + "/a/" + p.Category + "/" + p.ID
+}
+
+func (f *Feed) RSS() *RSS {
+	items := make([]RSSItem, 0, len(f.Posts))
+	for i := range f.Posts {
+		p := &f.Posts[i]
+		items = append(items, RSSItem{
+			Title:       p.Title,
+			Link:        f.PostLink(p),
+			Description: description(p),
+			Author:      p.Author.Username,
+			Category:    p.Category,
+			GUID:        RSSGUID{Value: p.ID},
+			PubDate:     p.Created.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	rss := &RSS{
+		Version: "2.0",
+		Channel: RSSChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Title,
+			Items:       items,
+		},
+	}
+
+	if lastModified := f.LastModified(); !lastModified.IsZero() {
+		rss.Channel.LastBuildDate = lastModified.UTC().Format(time.RFC1123Z)
+	}
+
+	return rss
+}
+
+func (f *Feed) Atom(selfLink string) *Atom {
+	entries := make([]AtomEntry, 0, len(f.Posts))
+	for i := range f.Posts {
+		p := &f.Posts[i]
+		link := f.PostLink(p)
+		entries = append(entries, AtomEntry{
+			Title:     p.Title,
+			ID:        link,
+			Link:      []AtomLink{{Href: link, Rel: "alternate"}},
+			Published: p.Created.UTC().Format(time.RFC3339),
+			Updated:   p.Created.UTC().Format(time.RFC3339),
+			Author:    AtomAuthor{Name: p.Author.Username},
+			Category:  AtomCategory{Term: p.Category},
+			Content:   AtomContent{Type: "text", Value: description(p)},
+		})
+	}
+
+	updated := f.LastModified()
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	return &Atom{
+		Title: f.Title,
+		ID:    f.Link,
+		Link: []AtomLink{
+			{Href: f.Link, Rel: "alternate"},
+			{Href: f.BaseURL + selfLink, Rel: "self"},
+		},
+		Updated: updated.UTC().Format(time.RFC3339),
+		Entries: entries,
+	}
+}
+
+func description(p *post.Post) string {
+	if len(p.URL) != 0 {
+		return p.URL
+	}
+
+	return p.Text
+}
@@ -0,0 +1,92 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/teatah/rclone/pkg/user"
+)
+
+type loaderCtxKey string
+
+const userLoaderKey = loaderCtxKey("userLoader")
+
+// userLoader batches the user lookups made while resolving one request. Load
+// only records the ID; the first thunk that is called fetches every recorded
+// ID with a single query.
+type userLoader struct {
+	mu      *sync.Mutex
+	repo    user.UserRepo
+	ctx     context.Context
+	pending map[string]struct{}
+	users   map[string]*user.User
+	err     error
+}
+
+func newUserLoader(ctx context.Context, repo user.UserRepo) *userLoader {
+	return &userLoader{
+		mu:      &sync.Mutex{},
+		repo:    repo,
+		ctx:     ctx,
+		pending: make(map[string]struct{}),
+		users:   make(map[string]*user.User),
+	}
+}
+
+// WithLoaders returns a context carrying fresh per-request loaders.
+func WithLoaders(ctx context.Context, userRepo user.UserRepo) context.Context {
+	return context.WithValue(ctx, userLoaderKey, newUserLoader(ctx, userRepo))
+}
+
+func userLoaderFromContext(ctx context.Context) *userLoader {
+	loader, _ := ctx.Value(userLoaderKey).(*userLoader)
+	return loader
+}
+
+func (l *userLoader) Load(userID string) func() (any, error) {
+	l.mu.Lock()
+	if _, ok := l.users[userID]; !ok {
+		l.pending[userID] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	return func() (any, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) != 0 {
+			l.fetchLocked()
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+
+		u := l.users[userID]
+		if u == nil {
+			return nil, nil
+		}
+
+		return u, nil
+	}
+}
+
+func (l *userLoader) fetchLocked() {
+	userIDs := make([]string, 0, len(l.pending))
+	for userID := range l.pending {
+		userIDs = append(userIDs, userID)
+	}
+	l.pending = make(map[string]struct{})
+
+	users, err := l.repo.UsersByIDs(l.ctx, userIDs)
+	if err != nil {
+		l.err = err
+		return
+	}
+
+	for _, userID := range userIDs {
+		l.users[userID] = nil
+	}
+	for _, u := range users {
+		l.users[u.ID] = u
+	}
+}
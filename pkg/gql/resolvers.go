@@ -0,0 +1,259 @@
+package gql
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/post"
+	"github.com/teatah/rclone/pkg/user"
+)
+
+func (res *resolver) post(p graphql.ResolveParams) (any, error) {
+	postID, _ := p.Args["id"].(string)
+
+	found, err := res.postRepo.Post(p.Context, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+func (res *resolver) posts(p graphql.ResolveParams) (any, error) {
+	var (
+		posts *[]post.Post
+		err   error
+	)
+
+	category, ok := p.Args["category"].(string)
+	if ok && len(category) != 0 {
+		posts, err = res.postRepo.PostsByCategory(p.Context, category)
+	} else {
+		posts, err = res.postRepo.AllPosts(p.Context)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return *posts, nil
+}
+
+func (res *resolver) user(p graphql.ResolveParams) (any, error) {
+	username, _ := p.Args["username"].(string)
+
+	found, err := res.userRepo.UserByName(p.Context, username)
+	if err == user.ErrUserNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+func (res *resolver) userPosts(p graphql.ResolveParams) (any, error) {
+	u, _ := p.Source.(*user.User)
+
+	posts, err := res.postRepo.PostsByUser(p.Context, u.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return *posts, nil
+}
+
+func (res *resolver) authorProfile(p graphql.ResolveParams) (any, error) {
+	var authorID string
+	switch author := p.Source.(type) {
+	case post.Author:
+		authorID = author.ID
+	case *post.Author:
+		authorID = author.ID
+	}
+
+	loader := userLoaderFromContext(p.Context)
+	if loader == nil {
+		loader = newUserLoader(p.Context, res.userRepo)
+	}
+
+	return loader.Load(authorID), nil
+}
+
+func (res *resolver) commentParentID(p graphql.ResolveParams) (any, error) {
+	c, _ := p.Source.(*post.Comment)
+	if len(c.ParentID) == 0 {
+		return nil, nil
+	}
+
+	return c.ParentID, nil
+}
+
+func (res *resolver) commentCount(p graphql.ResolveParams) (any, error) {
+	return len(sourcePost(p).Comments), nil
+}
+
+func (res *resolver) postComments(p graphql.ResolveParams) (any, error) {
+	comments := sourcePost(p).Comments
+
+	first, _ := p.Args["first"].(int)
+	offset, _ := p.Args["offset"].(int)
+
+	if offset < 0 || offset > len(comments) {
+		offset = len(comments)
+	}
+	end := offset + first
+	if first < 0 || end > len(comments) {
+		end = len(comments)
+	}
+
+	return comments[offset:end], nil
+}
+
+func (res *resolver) createPost(p graphql.ResolveParams) (any, error) {
+	author, err := res.currentAuthor(p)
+	if err != nil {
+		return nil, err
+	}
+
+	input, _ := p.Args["input"].(map[string]any)
+	postRequest := &post.PostRequest{}
+	postRequest.Category, _ = input["category"].(string)
+	postRequest.Type, _ = input["type"].(string)
+	postRequest.Title, _ = input["title"].(string)
+	postRequest.Text, _ = input["text"].(string)
+	postRequest.URL, _ = input["url"].(string)
+
+	newPost, err := res.postRepo.CreatePost(p.Context, author, postRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPost, nil
+}
+
+func (res *resolver) deletePost(p graphql.ResolveParams) (any, error) {
+	actor, err := res.currentUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, _ := p.Args["postId"].(string)
+
+	deletedPost, err := res.postRepo.DeletePost(p.Context, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := audit.NewEntry(audit.ActionPostDelete, audit.TargetPost, postID)
+	entry.ActorID, entry.Actor = actor.ID, actor.Username
+	entry.Before = audit.Snapshot(deletedPost)
+	res.audit.Record(p.Context, entry)
+
+	return true, nil
+}
+
+func (res *resolver) createComment(p graphql.ResolveParams) (any, error) {
+	author, err := res.currentAuthor(p)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, _ := p.Args["postId"].(string)
+	commentRequest := &post.CommentRequest{}
+	commentRequest.Comment, _ = p.Args["comment"].(string)
+	commentRequest.ParentID, _ = p.Args["parentId"].(string)
+
+	modifiedPost, err := res.postRepo.CreateComment(p.Context, postID, commentRequest, author)
+	if err != nil {
+		return nil, err
+	}
+
+	return modifiedPost, nil
+}
+
+func (res *resolver) deleteComment(p graphql.ResolveParams) (any, error) {
+	author, err := res.currentUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, _ := p.Args["postId"].(string)
+	commentID, _ := p.Args["commentId"].(string)
+
+	modifiedPost, deletedComment, err := res.postRepo.DeleteComment(p.Context, postID, commentID, author.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := audit.NewEntry(audit.ActionCommentDelete, audit.TargetComment, commentID)
+	entry.ActorID, entry.Actor = author.ID, author.Username
+	entry.Before = audit.Snapshot(deletedComment)
+	res.audit.Record(p.Context, entry)
+
+	return modifiedPost, nil
+}
+
+func (res *resolver) vote(p graphql.ResolveParams) (any, error) {
+	sess, err := sessionFromContext(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, _ := p.Args["postId"].(string)
+	voteVal, _ := p.Args["value"].(int)
+
+	modifiedPost, err := res.postRepo.Vote(p.Context, postID, sess.UserID, voteVal)
+	if err != nil {
+		return nil, err
+	}
+
+	return modifiedPost, nil
+}
+
+func (res *resolver) voteComment(p graphql.ResolveParams) (any, error) {
+	sess, err := sessionFromContext(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, _ := p.Args["postId"].(string)
+	commentID, _ := p.Args["commentId"].(string)
+	voteVal, _ := p.Args["value"].(int)
+
+	return res.postRepo.VoteComment(p.Context, postID, commentID, sess.UserID, voteVal)
+}
+
+func (res *resolver) currentUser(p graphql.ResolveParams) (*user.User, error) {
+	sess, err := sessionFromContext(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.userRepo.UserByID(p.Context, sess.UserID)
+}
+
+// currentAuthor returns the current user if they are allowed to post.
+func (res *resolver) currentAuthor(p graphql.ResolveParams) (*user.User, error) {
+	u, err := res.currentUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.userRepo.CanPost(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func sourcePost(p graphql.ResolveParams) *post.Post {
+	switch source := p.Source.(type) {
+	case *post.Post:
+		return source
+	case post.Post:
+		return &source
+	}
+
+	return &post.Post{}
+}
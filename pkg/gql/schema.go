@@ -0,0 +1,235 @@
+package gql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/post"
+	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+const defaultCommentsPage = 20
+
+var ErrUnauthorized = errors.New("authorization required")
+
+type resolver struct {
+	postRepo post.PostRepo
+	userRepo user.UserRepo
+	audit    *audit.Recorder
+	logger   *zap.SugaredLogger
+}
+
+// NewSchema builds the GraphQL schema over the post and user repositories.
+func NewSchema(
+	postRepo post.PostRepo,
+	userRepo user.UserRepo,
+	recorder *audit.Recorder,
+	logger *zap.SugaredLogger,
+) (graphql.Schema, error) {
+	res := &resolver{
+		postRepo: postRepo,
+		userRepo: userRepo,
+		audit:    recorder,
+		logger:   logger,
+	}
+
+	karmaType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Karma",
+		Fields: graphql.Fields{
+			"post":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"comment": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	var postType *graphql.Object
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"username":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"displayName": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"bio":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"avatar":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"created":     &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"karma":       &graphql.Field{Type: graphql.NewNonNull(karmaType)},
+				"posts": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+					Resolve: res.userPosts,
+				},
+			}
+		}),
+	})
+
+	authorType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Author",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"username": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"profile": &graphql.Field{
+				Type:    userType,
+				Resolve: res.authorProfile,
+			},
+		},
+	})
+
+	commentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Comment",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"body":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"created":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"score":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"parentId": &graphql.Field{Type: graphql.ID, Resolve: res.commentParentID},
+			"author":   &graphql.Field{Type: graphql.NewNonNull(authorType)},
+		},
+	})
+
+	postType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Post",
+		Fields: graphql.Fields{
+			"id":               &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"type":             &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"title":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"url":              &graphql.Field{Type: graphql.String},
+			"text":             &graphql.Field{Type: graphql.String},
+			"category":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"score":            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"views":            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"upvotePercentage": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"created":          &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"author":           &graphql.Field{Type: graphql.NewNonNull(authorType)},
+			"commentCount":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: res.commentCount},
+			"comments": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+				Args: graphql.FieldConfigArgument{
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultCommentsPage},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: res.postComments,
+			},
+		},
+	})
+
+	voteEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "VoteValue",
+		Values: graphql.EnumValueConfigMap{
+			"UP":   &graphql.EnumValueConfig{Value: post.Upvote},
+			"DOWN": &graphql.EnumValueConfig{Value: post.Downnvote},
+			"NONE": &graphql.EnumValueConfig{Value: post.Unvote},
+		},
+	})
+
+	postInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PostInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"category": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"type":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"title":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"text":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"url":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"post": &graphql.Field{
+				Type: postType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: res.post,
+			},
+			"posts": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+				Args: graphql.FieldConfigArgument{
+					"category": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: res.posts,
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"username": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: res.user,
+			},
+		},
+	})
+
+	postIDArgs := graphql.FieldConfigArgument{
+		"postId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+	}
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createPost": &graphql.Field{
+				Type: graphql.NewNonNull(postType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(postInput)},
+				},
+				Resolve: res.createPost,
+			},
+			"deletePost": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Args:    postIDArgs,
+				Resolve: res.deletePost,
+			},
+			"createComment": &graphql.Field{
+				Type: graphql.NewNonNull(postType),
+				Args: graphql.FieldConfigArgument{
+					"postId":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"comment":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"parentId": &graphql.ArgumentConfig{Type: graphql.ID},
+				},
+				Resolve: res.createComment,
+			},
+			"deleteComment": &graphql.Field{
+				Type: graphql.NewNonNull(postType),
+				Args: graphql.FieldConfigArgument{
+					"postId":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"commentId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: res.deleteComment,
+			},
+			"vote": &graphql.Field{
+				Type: graphql.NewNonNull(postType),
+				Args: graphql.FieldConfigArgument{
+					"postId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"value":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(voteEnum)},
+				},
+				Resolve: res.vote,
+			},
+			"voteComment": &graphql.Field{
+				Type: graphql.NewNonNull(postType),
+				Args: graphql.FieldConfigArgument{
+					"postId":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"commentId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"value":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(voteEnum)},
+				},
+				Resolve: res.voteComment,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+func sessionFromContext(ctx context.Context) (*session.Session, error) {
+	sess, ok := ctx.Value(session.SessionCtxValue("session")).(*session.Session)
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+
+	return sess, nil
+}
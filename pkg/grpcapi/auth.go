@@ -0,0 +1,98 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/grpcapi/forumpb"
+	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AuthServer struct {
+	forumpb.UnimplementedAuthServer
+
+	SessionManager *session.DBSessionManager
+	UserRepo       user.UserRepo
+	Audit          *audit.Recorder
+	Logger         *zap.SugaredLogger
+}
+
+func (as *AuthServer) Register(ctx context.Context, req *forumpb.Credentials) (*forumpb.TokenResponse, error) {
+	userRequest := &user.UserRequest{Username: req.GetUsername(), Password: req.GetPassword()}
+
+	u, err := as.UserRepo.Register(ctx, userRequest)
+	if err != nil {
+		var validationErr *user.ValidationError
+		switch {
+		case err == user.ErrUserAlreadyExists:
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		case errors.As(err, &validationErr):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, internalError(ctx, as.Logger, err)
+	}
+
+	entry := audit.NewEntry(audit.ActionRegister, audit.TargetUser, u.ID)
+	entry.ActorID, entry.Actor = u.ID, u.Username
+	as.Audit.Record(ctx, entry)
+
+	return as.createSession(ctx, u)
+}
+
+func (as *AuthServer) Login(ctx context.Context, req *forumpb.Credentials) (*forumpb.TokenResponse, error) {
+	userRequest := &user.UserRequest{Username: req.GetUsername(), Password: req.GetPassword()}
+
+	u, err := as.UserRepo.Login(ctx, userRequest)
+	if err != nil {
+		entry := audit.NewEntry(audit.ActionLoginFailed, audit.TargetUser, userRequest.Username)
+		entry.Actor = userRequest.Username
+		as.Audit.Record(ctx, entry)
+
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	entry := audit.NewEntry(audit.ActionLogin, audit.TargetUser, u.ID)
+	entry.ActorID, entry.Actor = u.ID, u.Username
+	as.Audit.Record(ctx, entry)
+
+	return as.createSession(ctx, u)
+}
+
+func (as *AuthServer) Refresh(ctx context.Context, req *forumpb.RefreshRequest) (*forumpb.TokenResponse, error) {
+	if len(req.GetRefreshToken()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "refresh token is required")
+	}
+
+	sess, err := as.SessionManager.Refresh(ctx, req.GetRefreshToken())
+
+	var reuseErr *session.RefreshReuseError
+	switch {
+	case errors.As(err, &reuseErr):
+		entry := audit.NewEntry(audit.ActionRefreshReuse, audit.TargetUser, reuseErr.UserID)
+		entry.ActorID = reuseErr.UserID
+		entry.After = audit.Snapshot(reuseErr)
+		as.Audit.Record(ctx, entry)
+
+		fallthrough
+	case errors.Is(err, session.ErrRefreshTokenInvalid), errors.Is(err, user.ErrUserBanned):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		return nil, internalError(ctx, as.Logger, err)
+	}
+
+	return &forumpb.TokenResponse{Token: sess.ID, RefreshToken: sess.RefreshToken}, nil
+}
+
+func (as *AuthServer) createSession(ctx context.Context, u *user.User) (*forumpb.TokenResponse, error) {
+	sess, err := as.SessionManager.Create(ctx, u)
+	if err != nil {
+		return nil, internalError(ctx, as.Logger, err)
+	}
+
+	return &forumpb.TokenResponse{Token: sess.ID, RefreshToken: sess.RefreshToken}, nil
+}
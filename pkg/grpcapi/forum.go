@@ -0,0 +1,270 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/grpcapi/forumpb"
+	"github.com/teatah/rclone/pkg/post"
+	"github.com/teatah/rclone/pkg/user"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type ForumServer struct {
+	forumpb.UnimplementedForumServer
+
+	PostRepo post.PostRepo
+	UserRepo user.UserRepo
+	Audit    *audit.Recorder
+	Logger   *zap.SugaredLogger
+}
+
+func (fs *ForumServer) ListPosts(ctx context.Context, req *forumpb.ListPostsRequest) (*forumpb.ListPostsResponse, error) {
+	var (
+		posts *[]post.Post
+		err   error
+	)
+
+	switch {
+	case len(req.GetCategory()) != 0:
+		posts, err = fs.PostRepo.PostsByCategory(ctx, req.GetCategory())
+	case len(req.GetUsername()) != 0:
+		posts, err = fs.PostRepo.PostsByUser(ctx, req.GetUsername())
+	default:
+		posts, err = fs.PostRepo.AllPosts(ctx)
+	}
+	if err != nil {
+		return nil, internalError(ctx, fs.Logger, err)
+	}
+
+	resp := &forumpb.ListPostsResponse{Posts: make([]*forumpb.Post, 0, len(*posts))}
+	for i := range *posts {
+		resp.Posts = append(resp.Posts, toProtoPost(&(*posts)[i]))
+	}
+
+	return resp, nil
+}
+
+func (fs *ForumServer) GetPost(ctx context.Context, req *forumpb.GetPostRequest) (*forumpb.Post, error) {
+	p, err := fs.PostRepo.Post(ctx, req.GetId())
+	if err != nil {
+		return nil, fs.postError(ctx, err)
+	}
+
+	return toProtoPost(p), nil
+}
+
+func (fs *ForumServer) CreatePost(ctx context.Context, req *forumpb.CreatePostRequest) (*forumpb.Post, error) {
+	author, err := fs.currentAuthor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	postRequest := &post.PostRequest{
+		Category: req.GetCategory(),
+		Type:     req.GetType(),
+		Title:    req.GetTitle(),
+		Text:     req.GetText(),
+		URL:      req.GetUrl(),
+	}
+
+	newPost, err := fs.PostRepo.CreatePost(ctx, author, postRequest)
+	if err != nil {
+		return nil, internalError(ctx, fs.Logger, err)
+	}
+
+	return toProtoPost(newPost), nil
+}
+
+func (fs *ForumServer) DeletePost(ctx context.Context, req *forumpb.DeletePostRequest) (*forumpb.DeletePostResponse, error) {
+	actor, err := fs.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deletedPost, err := fs.PostRepo.DeletePost(ctx, req.GetId())
+	if err != nil {
+		return nil, fs.postError(ctx, err)
+	}
+
+	entry := audit.NewEntry(audit.ActionPostDelete, audit.TargetPost, req.GetId())
+	entry.ActorID, entry.Actor = actor.ID, actor.Username
+	entry.Before = audit.Snapshot(deletedPost)
+	fs.Audit.Record(ctx, entry)
+
+	return &forumpb.DeletePostResponse{}, nil
+}
+
+func (fs *ForumServer) CreateComment(ctx context.Context, req *forumpb.CreateCommentRequest) (*forumpb.Post, error) {
+	if len(req.GetComment()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "comment is required")
+	}
+
+	author, err := fs.currentAuthor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	commentRequest := &post.CommentRequest{
+		Comment:  req.GetComment(),
+		ParentID: req.GetParentId(),
+	}
+
+	modifiedPost, err := fs.PostRepo.CreateComment(ctx, req.GetPostId(), commentRequest, author)
+	if err != nil {
+		return nil, fs.postError(ctx, err)
+	}
+
+	return toProtoPost(modifiedPost), nil
+}
+
+func (fs *ForumServer) DeleteComment(ctx context.Context, req *forumpb.DeleteCommentRequest) (*forumpb.Post, error) {
+	author, err := fs.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedPost, deletedComment, err := fs.PostRepo.DeleteComment(ctx, req.GetPostId(), req.GetCommentId(), author.Username)
+	if err != nil {
+		return nil, fs.postError(ctx, err)
+	}
+
+	entry := audit.NewEntry(audit.ActionCommentDelete, audit.TargetComment, req.GetCommentId())
+	entry.ActorID, entry.Actor = author.ID, author.Username
+	entry.Before = audit.Snapshot(deletedComment)
+	fs.Audit.Record(ctx, entry)
+
+	return toProtoPost(modifiedPost), nil
+}
+
+func (fs *ForumServer) Vote(ctx context.Context, req *forumpb.VoteRequest) (*forumpb.Post, error) {
+	sess, err := sessionFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedPost, err := fs.PostRepo.Vote(ctx, req.GetPostId(), sess.UserID, voteValue(req.GetValue()))
+	if err != nil {
+		return nil, fs.postError(ctx, err)
+	}
+
+	return toProtoPost(modifiedPost), nil
+}
+
+func (fs *ForumServer) VoteComment(ctx context.Context, req *forumpb.VoteCommentRequest) (*forumpb.Post, error) {
+	sess, err := sessionFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedPost, err := fs.PostRepo.VoteComment(
+		ctx,
+		req.GetPostId(),
+		req.GetCommentId(),
+		sess.UserID,
+		voteValue(req.GetValue()),
+	)
+	if err != nil {
+		return nil, fs.postError(ctx, err)
+	}
+
+	return toProtoPost(modifiedPost), nil
+}
+
+func (fs *ForumServer) currentUser(ctx context.Context) (*user.User, error) {
+	sess, err := sessionFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := fs.UserRepo.UserByID(ctx, sess.UserID)
+	if err != nil {
+		return nil, internalError(ctx, fs.Logger, err)
+	}
+
+	return u, nil
+}
+
+// currentAuthor returns the current user if they are allowed to post.
+func (fs *ForumServer) currentAuthor(ctx context.Context) (*user.User, error) {
+	u, err := fs.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.UserRepo.CanPost(u)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return u, nil
+}
+
+// postError converts an error of the post repository to a status: missing
+// posts and comments are NotFound and malformed IDs InvalidArgument.
+func (fs *ForumServer) postError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, post.ErrPostNotFound),
+		errors.Is(err, post.ErrCommentNotFound),
+		errors.Is(err, mongo.ErrNoDocuments):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, post.ErrInvalidID):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return internalError(ctx, fs.Logger, err)
+}
+
+func voteValue(v forumpb.VoteValue) int {
+	switch v {
+	case forumpb.VoteValue_VOTE_VALUE_UP:
+		return post.Upvote
+	case forumpb.VoteValue_VOTE_VALUE_DOWN:
+		return post.Downnvote
+	default:
+		return post.Unvote
+	}
+}
+
+func toProtoPost(p *post.Post) *forumpb.Post {
+	votes := make([]*forumpb.Vote, 0, len(p.Votes))
+	for _, v := range p.Votes {
+		votes = append(votes, &forumpb.Vote{User: v.User, Vote: int32(v.Vote)})
+	}
+
+	comments := make([]*forumpb.Comment, 0, len(p.Comments))
+	for _, c := range p.Comments {
+		comment := &forumpb.Comment{
+			Id:       c.ID,
+			Body:     c.Body,
+			ParentId: c.ParentID,
+			Score:    int32(c.Score),
+			Created:  timestamppb.New(c.Created),
+		}
+		if c.Author != nil {
+			comment.Author = &forumpb.Author{Id: c.Author.ID, Username: c.Author.Username}
+		}
+		comments = append(comments, comment)
+	}
+
+	return &forumpb.Post{
+		Id:               p.ID,
+		Type:             p.Type,
+		Title:            p.Title,
+		Url:              p.URL,
+		Text:             p.Text,
+		Category:         p.Category,
+		Score:            int32(p.Score),
+		Views:            int32(p.Views),
+		UpvotePercentage: int32(p.UpvotePercentage),
+		Author:           &forumpb.Author{Id: p.Author.ID, Username: p.Author.Username},
+		Votes:            votes,
+		Comments:         comments,
+		Created:          timestamppb.New(p.Created),
+	}
+}
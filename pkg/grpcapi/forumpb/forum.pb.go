@@ -0,0 +1,1262 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: forum.proto
+
+package forumpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type VoteValue int32
+
+const (
+	VoteValue_VOTE_VALUE_NONE VoteValue = 0
+	VoteValue_VOTE_VALUE_UP   VoteValue = 1
+	VoteValue_VOTE_VALUE_DOWN VoteValue = 2
+)
+
+// Enum value maps for VoteValue.
+var (
+	VoteValue_name = map[int32]string{
+		0: "VOTE_VALUE_NONE",
+		1: "VOTE_VALUE_UP",
+		2: "VOTE_VALUE_DOWN",
+	}
+	VoteValue_value = map[string]int32{
+		"VOTE_VALUE_NONE": 0,
+		"VOTE_VALUE_UP":   1,
+		"VOTE_VALUE_DOWN": 2,
+	}
+)
+
+func (x VoteValue) Enum() *VoteValue {
+	p := new(VoteValue)
+	*p = x
+	return p
+}
+
+func (x VoteValue) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (VoteValue) Descriptor() protoreflect.EnumDescriptor {
+	return file_forum_proto_enumTypes[0].Descriptor()
+}
+
+func (VoteValue) Type() protoreflect.EnumType {
+	return &file_forum_proto_enumTypes[0]
+}
+
+func (x VoteValue) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use VoteValue.Descriptor instead.
+func (VoteValue) EnumDescriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{0}
+}
+
+type Credentials struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Credentials) Reset() {
+	*x = Credentials{}
+	mi := &file_forum_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Credentials) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Credentials) ProtoMessage() {}
+
+func (x *Credentials) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Credentials.ProtoReflect.Descriptor instead.
+func (*Credentials) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Credentials) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Credentials) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+type TokenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	RefreshToken  string                 `protobuf:"bytes,2,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TokenResponse) Reset() {
+	*x = TokenResponse{}
+	mi := &file_forum_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenResponse) ProtoMessage() {}
+
+func (x *TokenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenResponse.ProtoReflect.Descriptor instead.
+func (*TokenResponse) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TokenResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *TokenResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+type RefreshRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RefreshToken  string                 `protobuf:"bytes,1,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshRequest) Reset() {
+	*x = RefreshRequest{}
+	mi := &file_forum_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshRequest) ProtoMessage() {}
+
+func (x *RefreshRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshRequest.ProtoReflect.Descriptor instead.
+func (*RefreshRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RefreshRequest) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+type Author struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Author) Reset() {
+	*x = Author{}
+	mi := &file_forum_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Author) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Author) ProtoMessage() {}
+
+func (x *Author) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Author.ProtoReflect.Descriptor instead.
+func (*Author) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Author) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Author) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type Vote struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	User          string                 `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Vote          int32                  `protobuf:"varint,2,opt,name=vote,proto3" json:"vote,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Vote) Reset() {
+	*x = Vote{}
+	mi := &file_forum_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Vote) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Vote) ProtoMessage() {}
+
+func (x *Vote) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Vote.ProtoReflect.Descriptor instead.
+func (*Vote) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Vote) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *Vote) GetVote() int32 {
+	if x != nil {
+		return x.Vote
+	}
+	return 0
+}
+
+type Comment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Author        *Author                `protobuf:"bytes,2,opt,name=author,proto3" json:"author,omitempty"`
+	Body          string                 `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	ParentId      string                 `protobuf:"bytes,4,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	Score         int32                  `protobuf:"varint,5,opt,name=score,proto3" json:"score,omitempty"`
+	Created       *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Comment) Reset() {
+	*x = Comment{}
+	mi := &file_forum_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Comment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Comment) ProtoMessage() {}
+
+func (x *Comment) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Comment.ProtoReflect.Descriptor instead.
+func (*Comment) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Comment) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Comment) GetAuthor() *Author {
+	if x != nil {
+		return x.Author
+	}
+	return nil
+}
+
+func (x *Comment) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Comment) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+func (x *Comment) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Comment) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+type Post struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Id               string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type             string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Title            string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Url              string                 `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	Text             string                 `protobuf:"bytes,5,opt,name=text,proto3" json:"text,omitempty"`
+	Category         string                 `protobuf:"bytes,6,opt,name=category,proto3" json:"category,omitempty"`
+	Score            int32                  `protobuf:"varint,7,opt,name=score,proto3" json:"score,omitempty"`
+	Views            int32                  `protobuf:"varint,8,opt,name=views,proto3" json:"views,omitempty"`
+	UpvotePercentage int32                  `protobuf:"varint,9,opt,name=upvote_percentage,json=upvotePercentage,proto3" json:"upvote_percentage,omitempty"`
+	Author           *Author                `protobuf:"bytes,10,opt,name=author,proto3" json:"author,omitempty"`
+	Votes            []*Vote                `protobuf:"bytes,11,rep,name=votes,proto3" json:"votes,omitempty"`
+	Comments         []*Comment             `protobuf:"bytes,12,rep,name=comments,proto3" json:"comments,omitempty"`
+	Created          *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Post) Reset() {
+	*x = Post{}
+	mi := &file_forum_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Post) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Post) ProtoMessage() {}
+
+func (x *Post) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Post.ProtoReflect.Descriptor instead.
+func (*Post) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Post) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Post) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Post) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Post) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Post) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Post) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Post) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Post) GetViews() int32 {
+	if x != nil {
+		return x.Views
+	}
+	return 0
+}
+
+func (x *Post) GetUpvotePercentage() int32 {
+	if x != nil {
+		return x.UpvotePercentage
+	}
+	return 0
+}
+
+func (x *Post) GetAuthor() *Author {
+	if x != nil {
+		return x.Author
+	}
+	return nil
+}
+
+func (x *Post) GetVotes() []*Vote {
+	if x != nil {
+		return x.Votes
+	}
+	return nil
+}
+
+func (x *Post) GetComments() []*Comment {
+	if x != nil {
+		return x.Comments
+	}
+	return nil
+}
+
+func (x *Post) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+// ListPostsRequest filters posts by category or by author username. Empty
+// filters list every post.
+type ListPostsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Category      string                 `protobuf:"bytes,1,opt,name=category,proto3" json:"category,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPostsRequest) Reset() {
+	*x = ListPostsRequest{}
+	mi := &file_forum_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPostsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPostsRequest) ProtoMessage() {}
+
+func (x *ListPostsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPostsRequest.ProtoReflect.Descriptor instead.
+func (*ListPostsRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListPostsRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *ListPostsRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type ListPostsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Posts         []*Post                `protobuf:"bytes,1,rep,name=posts,proto3" json:"posts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPostsResponse) Reset() {
+	*x = ListPostsResponse{}
+	mi := &file_forum_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPostsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPostsResponse) ProtoMessage() {}
+
+func (x *ListPostsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPostsResponse.ProtoReflect.Descriptor instead.
+func (*ListPostsResponse) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListPostsResponse) GetPosts() []*Post {
+	if x != nil {
+		return x.Posts
+	}
+	return nil
+}
+
+type GetPostRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPostRequest) Reset() {
+	*x = GetPostRequest{}
+	mi := &file_forum_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPostRequest) ProtoMessage() {}
+
+func (x *GetPostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPostRequest.ProtoReflect.Descriptor instead.
+func (*GetPostRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetPostRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CreatePostRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Category      string                 `protobuf:"bytes,1,opt,name=category,proto3" json:"category,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Title         string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Text          string                 `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	Url           string                 `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePostRequest) Reset() {
+	*x = CreatePostRequest{}
+	mi := &file_forum_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePostRequest) ProtoMessage() {}
+
+func (x *CreatePostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePostRequest.ProtoReflect.Descriptor instead.
+func (*CreatePostRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CreatePostRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *CreatePostRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CreatePostRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreatePostRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *CreatePostRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type DeletePostRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePostRequest) Reset() {
+	*x = DeletePostRequest{}
+	mi := &file_forum_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePostRequest) ProtoMessage() {}
+
+func (x *DeletePostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePostRequest.ProtoReflect.Descriptor instead.
+func (*DeletePostRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DeletePostRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeletePostResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePostResponse) Reset() {
+	*x = DeletePostResponse{}
+	mi := &file_forum_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePostResponse) ProtoMessage() {}
+
+func (x *DeletePostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePostResponse.ProtoReflect.Descriptor instead.
+func (*DeletePostResponse) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{12}
+}
+
+type CreateCommentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PostId        string                 `protobuf:"bytes,1,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	Comment       string                 `protobuf:"bytes,2,opt,name=comment,proto3" json:"comment,omitempty"`
+	ParentId      string                 `protobuf:"bytes,3,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateCommentRequest) Reset() {
+	*x = CreateCommentRequest{}
+	mi := &file_forum_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCommentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCommentRequest) ProtoMessage() {}
+
+func (x *CreateCommentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCommentRequest.ProtoReflect.Descriptor instead.
+func (*CreateCommentRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CreateCommentRequest) GetPostId() string {
+	if x != nil {
+		return x.PostId
+	}
+	return ""
+}
+
+func (x *CreateCommentRequest) GetComment() string {
+	if x != nil {
+		return x.Comment
+	}
+	return ""
+}
+
+func (x *CreateCommentRequest) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+type DeleteCommentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PostId        string                 `protobuf:"bytes,1,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	CommentId     string                 `protobuf:"bytes,2,opt,name=comment_id,json=commentId,proto3" json:"comment_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteCommentRequest) Reset() {
+	*x = DeleteCommentRequest{}
+	mi := &file_forum_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCommentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCommentRequest) ProtoMessage() {}
+
+func (x *DeleteCommentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCommentRequest.ProtoReflect.Descriptor instead.
+func (*DeleteCommentRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DeleteCommentRequest) GetPostId() string {
+	if x != nil {
+		return x.PostId
+	}
+	return ""
+}
+
+func (x *DeleteCommentRequest) GetCommentId() string {
+	if x != nil {
+		return x.CommentId
+	}
+	return ""
+}
+
+type VoteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PostId        string                 `protobuf:"bytes,1,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	Value         VoteValue              `protobuf:"varint,2,opt,name=value,proto3,enum=rclone.forum.v1.VoteValue" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VoteRequest) Reset() {
+	*x = VoteRequest{}
+	mi := &file_forum_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VoteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoteRequest) ProtoMessage() {}
+
+func (x *VoteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoteRequest.ProtoReflect.Descriptor instead.
+func (*VoteRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *VoteRequest) GetPostId() string {
+	if x != nil {
+		return x.PostId
+	}
+	return ""
+}
+
+func (x *VoteRequest) GetValue() VoteValue {
+	if x != nil {
+		return x.Value
+	}
+	return VoteValue_VOTE_VALUE_NONE
+}
+
+type VoteCommentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PostId        string                 `protobuf:"bytes,1,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	CommentId     string                 `protobuf:"bytes,2,opt,name=comment_id,json=commentId,proto3" json:"comment_id,omitempty"`
+	Value         VoteValue              `protobuf:"varint,3,opt,name=value,proto3,enum=rclone.forum.v1.VoteValue" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VoteCommentRequest) Reset() {
+	*x = VoteCommentRequest{}
+	mi := &file_forum_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VoteCommentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoteCommentRequest) ProtoMessage() {}
+
+func (x *VoteCommentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forum_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoteCommentRequest.ProtoReflect.Descriptor instead.
+func (*VoteCommentRequest) Descriptor() ([]byte, []int) {
+	return file_forum_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *VoteCommentRequest) GetPostId() string {
+	if x != nil {
+		return x.PostId
+	}
+	return ""
+}
+
+func (x *VoteCommentRequest) GetCommentId() string {
+	if x != nil {
+		return x.CommentId
+	}
+	return ""
+}
+
+func (x *VoteCommentRequest) GetValue() VoteValue {
+	if x != nil {
+		return x.Value
+	}
+	return VoteValue_VOTE_VALUE_NONE
+}
+
+var File_forum_proto protoreflect.FileDescriptor
+
+const file_forum_proto_rawDesc = "" +
+	"\n" +
+	"\vforum.proto\x12\x0frclone.forum.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"E\n" +
+	"\vCredentials\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"J\n" +
+	"\rTokenResponse\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12#\n" +
+	"\rrefresh_token\x18\x02 \x01(\tR\frefreshToken\"5\n" +
+	"\x0eRefreshRequest\x12#\n" +
+	"\rrefresh_token\x18\x01 \x01(\tR\frefreshToken\"4\n" +
+	"\x06Author\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\".\n" +
+	"\x04Vote\x12\x12\n" +
+	"\x04user\x18\x01 \x01(\tR\x04user\x12\x12\n" +
+	"\x04vote\x18\x02 \x01(\x05R\x04vote\"\xc7\x01\n" +
+	"\aComment\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12/\n" +
+	"\x06author\x18\x02 \x01(\v2\x17.rclone.forum.v1.AuthorR\x06author\x12\x12\n" +
+	"\x04body\x18\x03 \x01(\tR\x04body\x12\x1b\n" +
+	"\tparent_id\x18\x04 \x01(\tR\bparentId\x12\x14\n" +
+	"\x05score\x18\x05 \x01(\x05R\x05score\x124\n" +
+	"\acreated\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\acreated\"\xa5\x03\n" +
+	"\x04Post\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\x12\x12\n" +
+	"\x04text\x18\x05 \x01(\tR\x04text\x12\x1a\n" +
+	"\bcategory\x18\x06 \x01(\tR\bcategory\x12\x14\n" +
+	"\x05score\x18\a \x01(\x05R\x05score\x12\x14\n" +
+	"\x05views\x18\b \x01(\x05R\x05views\x12+\n" +
+	"\x11upvote_percentage\x18\t \x01(\x05R\x10upvotePercentage\x12/\n" +
+	"\x06author\x18\n" +
+	" \x01(\v2\x17.rclone.forum.v1.AuthorR\x06author\x12+\n" +
+	"\x05votes\x18\v \x03(\v2\x15.rclone.forum.v1.VoteR\x05votes\x124\n" +
+	"\bcomments\x18\f \x03(\v2\x18.rclone.forum.v1.CommentR\bcomments\x124\n" +
+	"\acreated\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\acreated\"J\n" +
+	"\x10ListPostsRequest\x12\x1a\n" +
+	"\bcategory\x18\x01 \x01(\tR\bcategory\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\"@\n" +
+	"\x11ListPostsResponse\x12+\n" +
+	"\x05posts\x18\x01 \x03(\v2\x15.rclone.forum.v1.PostR\x05posts\" \n" +
+	"\x0eGetPostRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x7f\n" +
+	"\x11CreatePostRequest\x12\x1a\n" +
+	"\bcategory\x18\x01 \x01(\tR\bcategory\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\"#\n" +
+	"\x11DeletePostRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x14\n" +
+	"\x12DeletePostResponse\"f\n" +
+	"\x14CreateCommentRequest\x12\x17\n" +
+	"\apost_id\x18\x01 \x01(\tR\x06postId\x12\x18\n" +
+	"\acomment\x18\x02 \x01(\tR\acomment\x12\x1b\n" +
+	"\tparent_id\x18\x03 \x01(\tR\bparentId\"N\n" +
+	"\x14DeleteCommentRequest\x12\x17\n" +
+	"\apost_id\x18\x01 \x01(\tR\x06postId\x12\x1d\n" +
+	"\n" +
+	"comment_id\x18\x02 \x01(\tR\tcommentId\"X\n" +
+	"\vVoteRequest\x12\x17\n" +
+	"\apost_id\x18\x01 \x01(\tR\x06postId\x120\n" +
+	"\x05value\x18\x02 \x01(\x0e2\x1a.rclone.forum.v1.VoteValueR\x05value\"~\n" +
+	"\x12VoteCommentRequest\x12\x17\n" +
+	"\apost_id\x18\x01 \x01(\tR\x06postId\x12\x1d\n" +
+	"\n" +
+	"comment_id\x18\x02 \x01(\tR\tcommentId\x120\n" +
+	"\x05value\x18\x03 \x01(\x0e2\x1a.rclone.forum.v1.VoteValueR\x05value*H\n" +
+	"\tVoteValue\x12\x13\n" +
+	"\x0fVOTE_VALUE_NONE\x10\x00\x12\x11\n" +
+	"\rVOTE_VALUE_UP\x10\x01\x12\x13\n" +
+	"\x0fVOTE_VALUE_DOWN\x10\x022\xe3\x01\n" +
+	"\x04Auth\x12H\n" +
+	"\bRegister\x12\x1c.rclone.forum.v1.Credentials\x1a\x1e.rclone.forum.v1.TokenResponse\x12E\n" +
+	"\x05Login\x12\x1c.rclone.forum.v1.Credentials\x1a\x1e.rclone.forum.v1.TokenResponse\x12J\n" +
+	"\aRefresh\x12\x1f.rclone.forum.v1.RefreshRequest\x1a\x1e.rclone.forum.v1.TokenResponse2\xe4\x04\n" +
+	"\x05Forum\x12R\n" +
+	"\tListPosts\x12!.rclone.forum.v1.ListPostsRequest\x1a\".rclone.forum.v1.ListPostsResponse\x12A\n" +
+	"\aGetPost\x12\x1f.rclone.forum.v1.GetPostRequest\x1a\x15.rclone.forum.v1.Post\x12G\n" +
+	"\n" +
+	"CreatePost\x12\".rclone.forum.v1.CreatePostRequest\x1a\x15.rclone.forum.v1.Post\x12U\n" +
+	"\n" +
+	"DeletePost\x12\".rclone.forum.v1.DeletePostRequest\x1a#.rclone.forum.v1.DeletePostResponse\x12M\n" +
+	"\rCreateComment\x12%.rclone.forum.v1.CreateCommentRequest\x1a\x15.rclone.forum.v1.Post\x12M\n" +
+	"\rDeleteComment\x12%.rclone.forum.v1.DeleteCommentRequest\x1a\x15.rclone.forum.v1.Post\x12;\n" +
+	"\x04Vote\x12\x1c.rclone.forum.v1.VoteRequest\x1a\x15.rclone.forum.v1.Post\x12I\n" +
+	"\vVoteComment\x12#.rclone.forum.v1.VoteCommentRequest\x1a\x15.rclone.forum.v1.PostB6Z4github.com/teatah/rclone/pkg/grpcapi/forumpb;forumpbb\x06proto3"
+
+var (
+	file_forum_proto_rawDescOnce sync.Once
+	file_forum_proto_rawDescData []byte
+)
+
+func file_forum_proto_rawDescGZIP() []byte {
+	file_forum_proto_rawDescOnce.Do(func() {
+		file_forum_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_forum_proto_rawDesc), len(file_forum_proto_rawDesc)))
+	})
+	return file_forum_proto_rawDescData
+}
+
+var file_forum_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_forum_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_forum_proto_goTypes = []any{
+	(VoteValue)(0),                // 0: rclone.forum.v1.VoteValue
+	(*Credentials)(nil),           // 1: rclone.forum.v1.Credentials
+	(*TokenResponse)(nil),         // 2: rclone.forum.v1.TokenResponse
+	(*RefreshRequest)(nil),        // 3: rclone.forum.v1.RefreshRequest
+	(*Author)(nil),                // 4: rclone.forum.v1.Author
+	(*Vote)(nil),                  // 5: rclone.forum.v1.Vote
+	(*Comment)(nil),               // 6: rclone.forum.v1.Comment
+	(*Post)(nil),                  // 7: rclone.forum.v1.Post
+	(*ListPostsRequest)(nil),      // 8: rclone.forum.v1.ListPostsRequest
+	(*ListPostsResponse)(nil),     // 9: rclone.forum.v1.ListPostsResponse
+	(*GetPostRequest)(nil),        // 10: rclone.forum.v1.GetPostRequest
+	(*CreatePostRequest)(nil),     // 11: rclone.forum.v1.CreatePostRequest
+	(*DeletePostRequest)(nil),     // 12: rclone.forum.v1.DeletePostRequest
+	(*DeletePostResponse)(nil),    // 13: rclone.forum.v1.DeletePostResponse
+	(*CreateCommentRequest)(nil),  // 14: rclone.forum.v1.CreateCommentRequest
+	(*DeleteCommentRequest)(nil),  // 15: rclone.forum.v1.DeleteCommentRequest
+	(*VoteRequest)(nil),           // 16: rclone.forum.v1.VoteRequest
+	(*VoteCommentRequest)(nil),    // 17: rclone.forum.v1.VoteCommentRequest
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
+}
+var file_forum_proto_depIdxs = []int32{
+	4,  // 0: rclone.forum.v1.Comment.author:type_name -> rclone.forum.v1.Author
+	18, // 1: rclone.forum.v1.Comment.created:type_name -> google.protobuf.Timestamp
+	4,  // 2: rclone.forum.v1.Post.author:type_name -> rclone.forum.v1.Author
+	5,  // 3: rclone.forum.v1.Post.votes:type_name -> rclone.forum.v1.Vote
+	6,  // 4: rclone.forum.v1.Post.comments:type_name -> rclone.forum.v1.Comment
+	18, // 5: rclone.forum.v1.Post.created:type_name -> google.protobuf.Timestamp
+	7,  // 6: rclone.forum.v1.ListPostsResponse.posts:type_name -> rclone.forum.v1.Post
+	0,  // 7: rclone.forum.v1.VoteRequest.value:type_name -> rclone.forum.v1.VoteValue
+	0,  // 8: rclone.forum.v1.VoteCommentRequest.value:type_name -> rclone.forum.v1.VoteValue
+	1,  // 9: rclone.forum.v1.Auth.Register:input_type -> rclone.forum.v1.Credentials
+	1,  // 10: rclone.forum.v1.Auth.Login:input_type -> rclone.forum.v1.Credentials
+	3,  // 11: rclone.forum.v1.Auth.Refresh:input_type -> rclone.forum.v1.RefreshRequest
+	8,  // 12: rclone.forum.v1.Forum.ListPosts:input_type -> rclone.forum.v1.ListPostsRequest
+	10, // 13: rclone.forum.v1.Forum.GetPost:input_type -> rclone.forum.v1.GetPostRequest
+	11, // 14: rclone.forum.v1.Forum.CreatePost:input_type -> rclone.forum.v1.CreatePostRequest
+	12, // 15: rclone.forum.v1.Forum.DeletePost:input_type -> rclone.forum.v1.DeletePostRequest
+	14, // 16: rclone.forum.v1.Forum.CreateComment:input_type -> rclone.forum.v1.CreateCommentRequest
+	15, // 17: rclone.forum.v1.Forum.DeleteComment:input_type -> rclone.forum.v1.DeleteCommentRequest
+	16, // 18: rclone.forum.v1.Forum.Vote:input_type -> rclone.forum.v1.VoteRequest
+	17, // 19: rclone.forum.v1.Forum.VoteComment:input_type -> rclone.forum.v1.VoteCommentRequest
+	2,  // 20: rclone.forum.v1.Auth.Register:output_type -> rclone.forum.v1.TokenResponse
+	2,  // 21: rclone.forum.v1.Auth.Login:output_type -> rclone.forum.v1.TokenResponse
+	2,  // 22: rclone.forum.v1.Auth.Refresh:output_type -> rclone.forum.v1.TokenResponse
+	9,  // 23: rclone.forum.v1.Forum.ListPosts:output_type -> rclone.forum.v1.ListPostsResponse
+	7,  // 24: rclone.forum.v1.Forum.GetPost:output_type -> rclone.forum.v1.Post
+	7,  // 25: rclone.forum.v1.Forum.CreatePost:output_type -> rclone.forum.v1.Post
+	13, // 26: rclone.forum.v1.Forum.DeletePost:output_type -> rclone.forum.v1.DeletePostResponse
+	7,  // 27: rclone.forum.v1.Forum.CreateComment:output_type -> rclone.forum.v1.Post
+	7,  // 28: rclone.forum.v1.Forum.DeleteComment:output_type -> rclone.forum.v1.Post
+	7,  // 29: rclone.forum.v1.Forum.Vote:output_type -> rclone.forum.v1.Post
+	7,  // 30: rclone.forum.v1.Forum.VoteComment:output_type -> rclone.forum.v1.Post
+	20, // [20:31] is the sub-list for method output_type
+	9,  // [9:20] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_forum_proto_init() }
+func file_forum_proto_init() {
+	if File_forum_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_forum_proto_rawDesc), len(file_forum_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_forum_proto_goTypes,
+		DependencyIndexes: file_forum_proto_depIdxs,
+		EnumInfos:         file_forum_proto_enumTypes,
+		MessageInfos:      file_forum_proto_msgTypes,
+	}.Build()
+	File_forum_proto = out.File
+	file_forum_proto_goTypes = nil
+	file_forum_proto_depIdxs = nil
+}
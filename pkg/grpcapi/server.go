@@ -0,0 +1,143 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/grpcapi/forumpb"
+	"github.com/teatah/rclone/pkg/post"
+	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/token"
+	"github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// publicMethods can be called without a bearer token.
+var publicMethods = map[string]bool{
+	forumpb.Auth_Register_FullMethodName:   true,
+	forumpb.Auth_Login_FullMethodName:      true,
+	forumpb.Auth_Refresh_FullMethodName:    true,
+	forumpb.Forum_ListPosts_FullMethodName: true,
+	forumpb.Forum_GetPost_FullMethodName:   true,
+}
+
+// NewServer returns a gRPC server exposing the Auth and Forum services over
+// the same repositories and session manager as the HTTP handlers.
+func NewServer(
+	sm *session.DBSessionManager,
+	postRepo post.PostRepo,
+	userRepo user.UserRepo,
+	recorder *audit.Recorder,
+	logger *zap.SugaredLogger,
+) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		clientInterceptor,
+		authInterceptor(sm, logger),
+	))
+
+	forumpb.RegisterAuthServer(server, &AuthServer{
+		SessionManager: sm,
+		UserRepo:       userRepo,
+		Audit:          recorder,
+		Logger:         logger,
+	})
+	forumpb.RegisterForumServer(server, &ForumServer{
+		PostRepo: postRepo,
+		UserRepo: userRepo,
+		Audit:    recorder,
+		Logger:   logger,
+	})
+
+	return server
+}
+
+// clientInterceptor stores the peer address and user agent of the call for
+// the audit log.
+func clientInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	client := &audit.Client{}
+
+	p, ok := peer.FromContext(ctx)
+	if ok {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		client.IP = host
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	userAgent := md.Get("user-agent")
+	if len(userAgent) != 0 {
+		client.UserAgent = userAgent[0]
+	}
+
+	return handler(audit.WithClient(ctx, client), req)
+}
+
+// authInterceptor checks the bearer token from the "authorization" metadata
+// and stores the session in the context the same way AuthMiddleware does.
+func authInterceptor(sm *session.DBSessionManager, logger *zap.SugaredLogger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		authorization := md.Get("authorization")
+		if len(authorization) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+
+		tokenString := strings.TrimPrefix(authorization[0], "Bearer ")
+
+		sess, err := sm.Check(ctx, tokenString)
+		var validationErr *token.ValidationError
+		switch {
+		case errors.As(err, &validationErr), errors.Is(err, session.ErrSessionNotFound):
+			logger.Infow("grpc authorization failed", "method", info.FullMethod, "error", err)
+			return nil, status.Error(codes.Unauthenticated, "invalid bearer token: "+err.Error())
+		case err != nil:
+			return nil, internalError(ctx, logger, err)
+		}
+
+		ctx = context.WithValue(ctx, session.SessionCtxValue("session"), sess)
+
+		return handler(ctx, req)
+	}
+}
+
+// internalError logs err and returns an Internal status that does not
+// disclose it, like ResponseContext.HandleError does for HTTP.
+func internalError(ctx context.Context, logger *zap.SugaredLogger, err error) error {
+	method, _ := grpc.Method(ctx)
+	logger.Errorw(err.Error(), "method", method)
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func sessionFromContext(ctx context.Context) (*session.Session, error) {
+	sess, ok := ctx.Value(session.SessionCtxValue("session")).(*session.Session)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing session")
+	}
+
+	return sess, nil
+}
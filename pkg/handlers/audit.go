@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/responses"
+	"go.uber.org/zap"
+)
+
+type AuditHandler struct {
+	Logger    *zap.SugaredLogger
+	AuditRepo audit.AuditRepo
+}
+
+// Entries lists audit entries, newest first, filtered by the actor,
+// targetType, target, from and to query parameters. Times are RFC 3339.
+func (ah *AuditHandler) Entries(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: ah.Logger, Writer: w, Request: r}
+
+	offset, limit, respErr := pageFromQuery(r)
+	if respErr != nil {
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := &audit.Filter{
+		Actor:      query.Get("actor"),
+		TargetType: query.Get("targetType"),
+		TargetID:   query.Get("target"),
+	}
+
+	var respErrs []*responses.ResponseError
+	for param, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(param)
+		if len(value) == 0 {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respErrs = append(respErrs, responses.NewResponseError("query", param, value, "must be an RFC 3339 time"))
+			continue
+		}
+		*t = parsed
+	}
+	if len(respErrs) != 0 {
+		rc.JSONError(http.StatusUnprocessableEntity, respErrs...)
+		return
+	}
+
+	entries, err := ah.AuditRepo.Entries(r.Context(), filter, offset, limit)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(entries)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/mail"
+	"github.com/teatah/rclone/pkg/responses"
+	"github.com/teatah/rclone/pkg/token"
+	userpkg "github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+// EmailVerifier mails the links that verify the email address of a user.
+type EmailVerifier struct {
+	UserRepo userpkg.UserRepo
+	Mailer   mail.Mailer
+	// TTL is how long a verification link works.
+	TTL time.Duration
+	// URL is where verification links point to; the token is added as the
+	// token query parameter.
+	URL string
+	// ResendInterval is how long a user waits before another link is sent.
+	ResendInterval time.Duration
+}
+
+// Send mails a verification link to the address of the user. It returns
+// userpkg.ErrVerificationThrottled when a link was sent less than
+// ResendInterval ago.
+func (ev *EmailVerifier) Send(ctx context.Context, u *userpkg.User) error {
+	err := ev.UserRepo.MarkVerificationSent(ctx, u.ID, ev.ResendInterval)
+	if err != nil {
+		return err
+	}
+
+	verifyToken, err := token.CreateEmailToken(u.ID, u.Email, ev.TTL)
+	if err != nil {
+		return err
+	}
+
+	link, err := url.Parse(ev.URL)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", verifyToken)
+	link.RawQuery = query.Encode()
+
+	msg := &mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nopen this link to confirm that this is your email address:\n\n%s\n\n"+
+				"The link expires at %s. If you did not add this address to an account, ignore this message.\n",
+			u.Username, link, time.Now().Add(ev.TTL).UTC().Format("2006-01-02 15:04 MST"),
+		),
+	}
+	err = ev.Mailer.Send(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to send verification link: %w", err)
+	}
+
+	return nil
+}
+
+type EmailHandler struct {
+	Logger   *zap.SugaredLogger
+	UserRepo userpkg.UserRepo
+	Verifier *EmailVerifier
+	Audit    *audit.Recorder
+}
+
+// GetEmail returns the email address of the caller and whether it is
+// verified.
+func (eh *EmailHandler) GetEmail(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: eh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	u, err := eh.UserRepo.UserByID(r.Context(), sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(&userpkg.EmailStatus{Email: u.Email, Verified: u.EmailVerified})
+}
+
+// SetEmail sets or removes the email address of the caller and mails a
+// verification link to a new address.
+func (eh *EmailHandler) SetEmail(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: eh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	emailRequest := &userpkg.EmailRequest{}
+	err = responses.ReadBody(r, emailRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	fe := userpkg.CheckEmail(emailRequest.Email)
+	if fe != nil {
+		respErr := responses.NewResponseError("body", fe.Field, fe.Value, fe.Msg)
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	ctx := r.Context()
+	before, err := eh.UserRepo.UserByID(ctx, sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	err = eh.UserRepo.SetEmail(ctx, sess.UserID, emailRequest.Email, false)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	u, err := eh.UserRepo.UserByID(ctx, sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	if u.Email != before.Email {
+		entry := audit.NewEntry(audit.ActionEmailChange, audit.TargetUser, u.ID)
+		entry.ActorID, entry.Actor = u.ID, u.Username
+		entry.Before = audit.Snapshot(map[string]string{"email": before.Email})
+		entry.After = audit.Snapshot(map[string]string{"email": u.Email})
+		eh.Audit.Record(ctx, entry)
+	}
+
+	if len(u.Email) != 0 && !u.EmailVerified {
+		err = eh.Verifier.Send(ctx, u)
+		if err != nil && err != userpkg.ErrVerificationThrottled {
+			rc.LogError(err)
+		}
+	}
+
+	rc.WriteRawDataToBody(&userpkg.EmailStatus{Email: u.Email, Verified: u.EmailVerified})
+}
+
+// ResendVerification mails another verification link to the caller, at most
+// once per resend interval.
+func (eh *EmailHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: eh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	ctx := r.Context()
+	u, err := eh.UserRepo.UserByID(ctx, sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	switch {
+	case len(u.Email) == 0:
+		respErr := responses.NewResponseError("body", "email", "", "is not set")
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	case u.EmailVerified:
+		respErr := responses.NewResponseError("body", "email", u.Email, "is already verified")
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	err = eh.Verifier.Send(ctx, u)
+	if err == userpkg.ErrVerificationThrottled {
+		w.Header().Set("Retry-After", strconv.Itoa(int(eh.Verifier.ResendInterval/time.Second)))
+		respErr := responses.NewResponseError("body", "email", u.Email, err.Error())
+		rc.JSONError(http.StatusTooManyRequests, respErr)
+		return
+	}
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+// Verify marks an email address as verified with the token from a
+// verification link. It needs no session, so the link works on any device.
+func (eh *EmailHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: eh.Logger, Writer: w, Request: r}
+
+	claims, err := token.ParseEmailToken(r.URL.Query().Get("token"))
+	if err != nil {
+		respErr := responses.NewResponseError("query", "token", "", userpkg.ErrVerificationInvalid.Error())
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	ctx := r.Context()
+	err = eh.UserRepo.VerifyEmail(ctx, claims.Subject, claims.Email)
+	if err == userpkg.ErrVerificationInvalid {
+		respErr := responses.NewResponseError("query", "token", "", err.Error())
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	entry := audit.NewEntry(audit.ActionEmailVerify, audit.TargetUser, claims.Subject)
+	entry.ActorID = claims.Subject
+	eh.Audit.Record(ctx, entry)
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/feed"
+	postpkg "github.com/teatah/rclone/pkg/post"
+	"github.com/teatah/rclone/pkg/responses"
+	"go.uber.org/zap"
+)
+
+const (
+	formatRSS  = "rss"
+	formatAtom = "atom"
+)
+
+type FeedHandler struct {
+	Logger   *zap.SugaredLogger
+	PostRepo postpkg.PostRepo
+}
+
+func (fh *FeedHandler) AllPostsRSS(w http.ResponseWriter, r *http.Request) {
+	fh.allPosts(w, r, formatRSS)
+}
+
+func (fh *FeedHandler) AllPostsAtom(w http.ResponseWriter, r *http.Request) {
+	fh.allPosts(w, r, formatAtom)
+}
+
+func (fh *FeedHandler) CategoryRSS(w http.ResponseWriter, r *http.Request) {
+	fh.category(w, r, formatRSS)
+}
+
+func (fh *FeedHandler) CategoryAtom(w http.ResponseWriter, r *http.Request) {
+	fh.category(w, r, formatAtom)
+}
+
+func (fh *FeedHandler) UserRSS(w http.ResponseWriter, r *http.Request) {
+	fh.user(w, r, formatRSS)
+}
+
+func (fh *FeedHandler) UserAtom(w http.ResponseWriter, r *http.Request) {
+	fh.user(w, r, formatAtom)
+}
+
+func (fh *FeedHandler) allPosts(w http.ResponseWriter, r *http.Request, format string) {
+	rc := &responses.ResponseContext{Logger: fh.Logger, Writer: w, Request: r}
+
+	posts, err := fh.PostRepo.AllPosts(r.Context())
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	f := feed.New("rclone: all posts", baseURL(r), "/", *posts)
+	fh.write(rc, f, format)
+}
+
+func (fh *FeedHandler) category(w http.ResponseWriter, r *http.Request, format string) {
+	rc := &responses.ResponseContext{Logger: fh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	category := vars["category"]
+
+	posts, err := fh.PostRepo.PostsByCategory(r.Context(), category)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	f := feed.New("rclone: "+category, baseURL(r), "/a/"+category, *posts)
+	fh.write(rc, f, format)
+}
+
+func (fh *FeedHandler) user(w http.ResponseWriter, r *http.Request, format string) {
+	rc := &responses.ResponseContext{Logger: fh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	posts, err := fh.PostRepo.PostsByUser(r.Context(), username)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	f := feed.New("rclone: posts by "+username, baseURL(r), "/u/"+username, *posts)
+	fh.write(rc, f, format)
+}
+
+func (fh *FeedHandler) write(rc *responses.ResponseContext, f *feed.Feed, format string) {
+	w := rc.Writer
+
+	lastModified := f.LastModified().Truncate(time.Second)
+	if !lastModified.IsZero() {
+		ifModifiedSince, err := http.ParseTime(rc.Request.Header.Get("If-Modified-Since"))
+		if err == nil && !lastModified.After(ifModifiedSince) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	var doc any
+	switch format {
+	case formatAtom:
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		doc = f.Atom(rc.Request.URL.Path)
+	default:
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		doc = f.RSS()
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	_, err = w.Write([]byte(xml.Header))
+	if err == nil {
+		_, err = w.Write(body)
+	}
+	if err != nil {
+		rc.LogError(err)
+	}
+}
+
+// baseURL returns the scheme and host the client used to reach the server.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) != 0 {
+		scheme = strings.ToLower(proto)
+	}
+
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/teatah/rclone/pkg/gql"
+	"github.com/teatah/rclone/pkg/responses"
+	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/token"
+	userpkg "github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type GraphQLHandler struct {
+	SessionManager *session.DBSessionManager
+	Logger         *zap.SugaredLogger
+	Schema         graphql.Schema
+	UserRepo       userpkg.UserRepo
+}
+
+// ServeHTTP executes a GraphQL query. Authorization is optional: mutations
+// fail unless the request carries a valid bearer token.
+func (gh *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: gh.Logger, Writer: w, Request: r}
+
+	gqlRequest := &GraphQLRequest{}
+	if r.Method == http.MethodGet {
+		gqlRequest.Query = r.URL.Query().Get("query")
+		gqlRequest.OperationName = r.URL.Query().Get("operationName")
+	} else {
+		err := responses.ReadBody(r, gqlRequest)
+		if err != nil {
+			respErr := responses.NewResponseError("body", "query", "", "invalid GraphQL request")
+			rc.JSONError(http.StatusBadRequest, respErr)
+			return
+		}
+	}
+
+	if len(gqlRequest.Query) == 0 {
+		respErr := responses.NewResponseError("body", "query", "", "is required")
+		rc.JSONError(http.StatusBadRequest, respErr)
+		return
+	}
+
+	ctx := r.Context()
+	tokenString := token.TokenFromHeader(r)
+	if len(tokenString) != 0 {
+		sess, err := gh.SessionManager.Check(ctx, tokenString)
+		if err == nil {
+			ctx = context.WithValue(ctx, session.SessionCtxValue("session"), sess)
+		}
+	}
+	ctx = gql.WithLoaders(ctx, gh.UserRepo)
+
+	result := graphql.Do(graphql.Params{
+		Schema:         gh.Schema,
+		RequestString:  gqlRequest.Query,
+		VariableValues: gqlRequest.Variables,
+		OperationName:  gqlRequest.OperationName,
+		Context:        ctx,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	rc.WriteRawDataToBody(result)
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/teatah/rclone/pkg/responses"
+	"github.com/teatah/rclone/pkg/token"
+	"go.uber.org/zap"
+)
+
+type JWKSHandler struct {
+	Logger *zap.SugaredLogger
+	KeySet *token.KeySet
+}
+
+// Keys serves the public token keys so that other services can verify the
+// tokens issued here. Retired keys stay listed until they are removed from
+// the configuration.
+func (jh *JWKSHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: jh.Logger, Writer: w, Request: r}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	rc.WriteRawDataToBody(jh.KeySet.JWKS())
+}
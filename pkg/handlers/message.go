@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/message"
+	"github.com/teatah/rclone/pkg/responses"
+	userpkg "github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+type MessageHandler struct {
+	Logger      *zap.SugaredLogger
+	MessageRepo message.MessageRepo
+	UserRepo    userpkg.UserRepo
+}
+
+func (mh *MessageHandler) Send(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: mh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	messageRequest := &message.MessageRequest{}
+	err := responses.ReadBody(r, messageRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	switch {
+	case len(strings.TrimSpace(messageRequest.Message)) == 0:
+		respErr := responses.NewResponseError("body", "message", "", "is required")
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	case utf8.RuneCountInString(messageRequest.Message) > message.MaxMessageLen:
+		respErr := responses.NewResponseError("body", "message", "", "is too long")
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	ctx := r.Context()
+	recipient, ok := mh.userByName(rc, username)
+	if !ok {
+		return
+	}
+
+	msg, err := mh.MessageRepo.Send(ctx, sess.UserID, recipient.ID, messageRequest.Message)
+	if err != nil {
+		switch err {
+		case message.ErrBlocked:
+			respErr := responses.NewResponseError("url", "username", username, err.Error())
+			rc.JSONError(http.StatusForbidden, respErr)
+		case message.ErrSelfMessage:
+			respErr := responses.NewResponseError("url", "username", username, err.Error())
+			rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		default:
+			rc.HandleError(err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	rc.WriteRawDataToBody(msg)
+}
+
+func (mh *MessageHandler) Conversations(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: mh.Logger, Writer: w, Request: r}
+
+	offset, limit, respErr := pageFromQuery(r)
+	if respErr != nil {
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	conversations, err := mh.MessageRepo.Conversations(r.Context(), sess.UserID, offset, limit)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(conversations)
+}
+
+func (mh *MessageHandler) Messages(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: mh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	conversationID := vars["conversationID"]
+
+	offset, limit, respErr := pageFromQuery(r)
+	if respErr != nil {
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	messages, err := mh.MessageRepo.Messages(r.Context(), sess.UserID, conversationID, offset, limit)
+	if err != nil {
+		mh.handleConversationError(rc, conversationID, err)
+		return
+	}
+
+	rc.WriteRawDataToBody(messages)
+}
+
+func (mh *MessageHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: mh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	conversationID := vars["conversationID"]
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	err = mh.MessageRepo.MarkRead(r.Context(), sess.UserID, conversationID)
+	if err != nil {
+		mh.handleConversationError(rc, conversationID, err)
+		return
+	}
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+func (mh *MessageHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: mh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	unread, err := mh.MessageRepo.UnreadCount(r.Context(), sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(message.UnreadCount{Unread: unread})
+}
+
+func (mh *MessageHandler) Block(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: mh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	blocked, ok := mh.userByName(rc, username)
+	if !ok {
+		return
+	}
+
+	err = mh.MessageRepo.Block(r.Context(), sess.UserID, blocked.ID)
+	if err != nil {
+		if err == message.ErrSelfMessage {
+			respErr := responses.NewResponseError("url", "username", username, "cannot block yourself")
+			rc.JSONError(http.StatusUnprocessableEntity, respErr)
+			return
+		}
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+func (mh *MessageHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: mh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	blocked, ok := mh.userByName(rc, username)
+	if !ok {
+		return
+	}
+
+	err = mh.MessageRepo.Unblock(r.Context(), sess.UserID, blocked.ID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+func (mh *MessageHandler) Blocked(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: mh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	blocked, err := mh.MessageRepo.Blocked(r.Context(), sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(blocked)
+}
+
+// userByName looks up the user named in the URL and writes a 404 response if
+// there is none.
+func (mh *MessageHandler) userByName(rc *responses.ResponseContext, username string) (*userpkg.User, bool) {
+	user, err := mh.UserRepo.UserByName(rc.Request.Context(), username)
+	if err != nil {
+		if err == userpkg.ErrUserNotFound {
+			respErr := responses.NewResponseError("url", "username", username, err.Error())
+			rc.JSONError(http.StatusNotFound, respErr)
+			return nil, false
+		}
+		rc.HandleError(err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (mh *MessageHandler) handleConversationError(rc *responses.ResponseContext, conversationID string, err error) {
+	if err == message.ErrConversationNotFound {
+		respErr := responses.NewResponseError("url", "conversationID", conversationID, err.Error())
+		rc.JSONError(http.StatusNotFound, respErr)
+		return
+	}
+
+	rc.HandleError(err)
+}
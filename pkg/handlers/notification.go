@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/notification"
+	"github.com/teatah/rclone/pkg/responses"
+	"go.uber.org/zap"
+)
+
+type NotificationHandler struct {
+	Logger           *zap.SugaredLogger
+	NotificationRepo notification.NotificationRepo
+}
+
+func (nh *NotificationHandler) Notifications(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: nh.Logger, Writer: w, Request: r}
+
+	offset, limit, respErr := pageFromQuery(r)
+	if respErr != nil {
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	notifications, err := nh.NotificationRepo.Notifications(r.Context(), sess.UserID, unreadOnly, offset, limit)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(notifications)
+}
+
+func (nh *NotificationHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: nh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	unread, err := nh.NotificationRepo.UnreadCount(r.Context(), sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(notification.UnreadCount{Unread: unread})
+}
+
+func (nh *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: nh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	notificationID := vars["notificationID"]
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	err = nh.NotificationRepo.MarkRead(r.Context(), sess.UserID, notificationID)
+	if err != nil {
+		if err == notification.ErrNotificationNotFound {
+			respErr := responses.NewResponseError("url", "notificationID", notificationID, err.Error())
+			rc.JSONError(http.StatusNotFound, respErr)
+			return
+		}
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+func (nh *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: nh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	err = nh.NotificationRepo.MarkAllRead(r.Context(), sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+func (nh *NotificationHandler) Settings(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: nh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	settings, err := nh.NotificationRepo.Settings(r.Context(), sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(settings)
+}
+
+func (nh *NotificationHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: nh.Logger, Writer: w, Request: r}
+
+	settings := &notification.Settings{}
+	err := responses.ReadBody(r, settings)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	if settings.Muted == nil {
+		settings.Muted = make([]string, 0)
+	}
+
+	var respErrs []*responses.ResponseError
+	for _, muted := range settings.Muted {
+		if !notification.IsValidType(muted) {
+			respErrs = append(respErrs,
+				responses.NewResponseError("body", "muted", muted, "unknown notification type"))
+		}
+	}
+	if len(respErrs) != 0 {
+		rc.JSONError(http.StatusUnprocessableEntity, respErrs...)
+		return
+	}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+	settings.UserID = sess.UserID
+
+	err = nh.NotificationRepo.UpdateSettings(r.Context(), settings)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(settings)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/teatah/rclone/pkg/responses"
+)
+
+const (
+	defaultPageLimit = 25
+	maxPageLimit     = 100
+)
+
+// pageFromQuery reads the 1-based "page" and the "limit" query parameters and
+// converts them to an offset and a limit.
+func pageFromQuery(r *http.Request) (int, int, *responses.ResponseError) {
+	query := r.URL.Query()
+
+	page := 1
+	if pageParam := query.Get("page"); len(pageParam) != 0 {
+		p, err := strconv.Atoi(pageParam)
+		if err != nil || p < 1 {
+			return 0, 0, responses.NewResponseError("query", "page", pageParam, "must be a positive integer")
+		}
+		page = p
+	}
+
+	limit := defaultPageLimit
+	if limitParam := query.Get("limit"); len(limitParam) != 0 {
+		l, err := strconv.Atoi(limitParam)
+		if err != nil || l < 1 || l > maxPageLimit {
+			return 0, 0, responses.NewResponseError(
+				"query", "limit", limitParam, "must be an integer between 1 and "+strconv.Itoa(maxPageLimit),
+			)
+		}
+		limit = l
+	}
+
+	return (page - 1) * limit, limit, nil
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/mail"
+	"github.com/teatah/rclone/pkg/responses"
+	"github.com/teatah/rclone/pkg/session"
+	userpkg "github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+type PasswordHandler struct {
+	Logger         *zap.SugaredLogger
+	UserRepo       userpkg.UserRepo
+	SessionManager *session.DBSessionManager
+	Mailer         mail.Mailer
+	Audit          *audit.Recorder
+	// ResetTTL is how long a reset link works.
+	ResetTTL time.Duration
+	// ResetURL is the page reset links point to; the token is added as the
+	// token query parameter.
+	ResetURL string
+}
+
+// ChangePassword sets a new password for the caller and ends their other
+// sessions.
+func (pwh *PasswordHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: pwh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	changeRequest := &userpkg.PasswordChangeRequest{}
+	err = responses.ReadBody(r, changeRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	ctx := r.Context()
+	err = pwh.UserRepo.ChangePassword(ctx, sess.UserID, changeRequest.CurrentPassword, changeRequest.NewPassword)
+	if err != nil {
+		if err == userpkg.ErrInvalidPassword {
+			respErr := responses.NewResponseError("body", "currentPassword", "", "is incorrect")
+			rc.JSONError(http.StatusUnprocessableEntity, respErr)
+			return
+		}
+		pwh.handlePasswordError(rc, err)
+		return
+	}
+
+	_, err = pwh.SessionManager.RevokeOthers(ctx, sess.UserID, sess)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	u, err := pwh.UserRepo.UserByID(ctx, sess.UserID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	entry := audit.NewEntry(audit.ActionPasswordChange, audit.TargetUser, u.ID)
+	entry.ActorID, entry.Actor = u.ID, u.Username
+	pwh.Audit.Record(ctx, entry)
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+// RequestReset mails a reset link to the user. It answers the same whether
+// or not the user exists or has a verified email address, so it cannot be
+// used to find out either.
+func (pwh *PasswordHandler) RequestReset(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: pwh.Logger, Writer: w, Request: r}
+
+	resetRequest := &userpkg.PasswordResetRequest{}
+	err := responses.ReadBody(r, resetRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	if len(resetRequest.Username) == 0 {
+		respErr := responses.NewResponseError("body", "username", "", "is required")
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	err = pwh.sendReset(r, resetRequest.Username)
+	if err != nil {
+		rc.LogError(err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+// ConfirmReset sets the password with a token from a reset link and ends all
+// sessions of the user.
+func (pwh *PasswordHandler) ConfirmReset(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: pwh.Logger, Writer: w, Request: r}
+
+	confirmRequest := &userpkg.PasswordResetConfirmRequest{}
+	err := responses.ReadBody(r, confirmRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	if len(confirmRequest.Token) == 0 {
+		respErr := responses.NewResponseError("body", "token", "", "is required")
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	ctx := r.Context()
+	u, err := pwh.UserRepo.ResetPassword(ctx, confirmRequest.Token, confirmRequest.Password)
+	if err != nil {
+		if err == userpkg.ErrResetTokenInvalid {
+			respErr := responses.NewResponseError("body", "token", "", err.Error())
+			rc.JSONError(http.StatusUnprocessableEntity, respErr)
+			return
+		}
+		pwh.handlePasswordError(rc, err)
+		return
+	}
+
+	_, err = pwh.SessionManager.RevokeUserSessions(ctx, u.ID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	entry := audit.NewEntry(audit.ActionPasswordReset, audit.TargetUser, u.ID)
+	entry.ActorID, entry.Actor = u.ID, u.Username
+	pwh.Audit.Record(ctx, entry)
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+// sendReset mails a reset link to the user with the given name if they can
+// log in and have a verified email address.
+func (pwh *PasswordHandler) sendReset(r *http.Request, username string) error {
+	ctx := r.Context()
+
+	u, err := pwh.UserRepo.UserByName(ctx, username)
+	if err == userpkg.ErrUserNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if u.Banned || !u.EmailVerified {
+		return nil
+	}
+
+	resetToken, err := pwh.UserRepo.CreatePasswordReset(ctx, u.ID, pwh.ResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link, err := url.Parse(pwh.ResetURL)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", resetToken)
+	link.RawQuery = query.Encode()
+
+	msg := &mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nsomeone asked to reset the password of your account. "+
+				"Open this link to choose a new one:\n\n%s\n\n"+
+				"The link works once and expires at %s. "+
+				"If it was not you, ignore this message and your password stays the same.\n",
+			u.Username, link, time.Now().Add(pwh.ResetTTL).UTC().Format("2006-01-02 15:04 MST"),
+		),
+	}
+	err = pwh.Mailer.Send(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to send reset link: %w", err)
+	}
+
+	entry := audit.NewEntry(audit.ActionPasswordResetRequest, audit.TargetUser, u.ID)
+	entry.Actor = username
+	pwh.Audit.Record(ctx, entry)
+
+	return nil
+}
+
+// handlePasswordError answers with the policy violations in err, if any.
+func (pwh *PasswordHandler) handlePasswordError(rc *responses.ResponseContext, err error) {
+	var validationErr *userpkg.ValidationError
+	if !errors.As(err, &validationErr) {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.JSONError(http.StatusUnprocessableEntity, fieldErrors(validationErr)...)
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/mail"
+	"github.com/teatah/rclone/pkg/migrate"
+	"github.com/teatah/rclone/pkg/session"
+	userpkg "github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+const (
+	testEmail    = "alice@example.com"
+	testPassword = "correct horse battery"
+)
+
+var linkRe = regexp.MustCompile(`https?://\S+`)
+
+// testPool connects to the database in TEST_POSTGRES_URL with a migrated
+// schema of its own, which is dropped when the test ends.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	connString := os.Getenv("TEST_POSTGRES_URL")
+	if len(connString) == 0 {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("handlers_test_%d", time.Now().UnixNano())
+
+	admin, err := pgxpool.New(ctx, connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolConfig.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrator, err := migrate.NewPGMigrator(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pool
+}
+
+// newTestPasswordHandler returns a handler mailing into a MemoryMailer and a
+// registered user with a verified email address.
+func newTestPasswordHandler(t *testing.T) (*PasswordHandler, *mail.MemoryMailer, *userpkg.User) {
+	t.Helper()
+
+	ctx := context.Background()
+	pool := testPool(t)
+	logger := zap.NewNop().Sugar()
+
+	userRepo := userpkg.NewUserDBRepo(pool)
+	u, err := userRepo.Register(ctx, &userpkg.UserRequest{Username: "alice", Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = userRepo.SetEmail(ctx, u.ID, testEmail, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mailer := mail.NewMemoryMailer()
+	pwh := &PasswordHandler{
+		Logger:         logger,
+		UserRepo:       userRepo,
+		SessionManager: session.NewDBSessionManager(pool, session.NewPGStore(pool)),
+		Mailer:         mailer,
+		Audit:          audit.NewRecorder(audit.NewAuditDBRepo(pool), logger),
+		ResetTTL:       time.Hour,
+		ResetURL:       "http://localhost:8080/password/reset",
+	}
+
+	return pwh, mailer, u
+}
+
+// requestReset asks for a reset link for the user and returns the token from
+// the link that was mailed.
+func requestReset(t *testing.T, pwh *PasswordHandler, mailer *mail.MemoryMailer, username string) string {
+	t.Helper()
+
+	sent := len(mailer.Messages())
+
+	rec := httptest.NewRecorder()
+	body := fmt.Sprintf(`{"username":%q}`, username)
+	pwh.RequestReset(rec, httptest.NewRequest(http.MethodPost, "/api/password/reset", strings.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("reset request answered %d, want %d", rec.Code, http.StatusAccepted)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != sent+1 {
+		t.Fatalf("%d messages sent, want 1", len(messages)-sent)
+	}
+	msg := messages[len(messages)-1]
+	if msg.To != testEmail {
+		t.Errorf("reset link sent to %q, want %q", msg.To, testEmail)
+	}
+
+	link, err := url.Parse(linkRe.FindString(msg.Body))
+	if err != nil {
+		t.Fatalf("no reset link in %q: %s", msg.Body, err)
+	}
+	resetToken := link.Query().Get("token")
+	if len(resetToken) == 0 {
+		t.Fatalf("no token in reset link %s", link)
+	}
+
+	return resetToken
+}
+
+func confirmReset(pwh *PasswordHandler, resetToken string, password string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	body := fmt.Sprintf(`{"token":%q,"password":%q}`, resetToken, password)
+	pwh.ConfirmReset(rec, httptest.NewRequest(http.MethodPost, "/api/password/reset/confirm", strings.NewReader(body)))
+
+	return rec
+}
+
+func TestPasswordResetWorksOnce(t *testing.T) {
+	pwh, mailer, u := newTestPasswordHandler(t)
+	resetToken := requestReset(t, pwh, mailer, u.Username)
+
+	rec := confirmReset(pwh, resetToken, "a brand new passphrase")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset answered %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	_, err := pwh.UserRepo.Login(context.Background(), &userpkg.UserRequest{
+		Username: u.Username,
+		Password: "a brand new passphrase",
+	})
+	if err != nil {
+		t.Errorf("logging in with the new password: %s", err)
+	}
+
+	rec = confirmReset(pwh, resetToken, "yet another passphrase")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("second reset with the same token answered %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestPasswordResetExpires(t *testing.T) {
+	pwh, mailer, u := newTestPasswordHandler(t)
+	pwh.ResetTTL = 10 * time.Millisecond
+	resetToken := requestReset(t, pwh, mailer, u.Username)
+
+	time.Sleep(50 * time.Millisecond)
+
+	rec := confirmReset(pwh, resetToken, "a brand new passphrase")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reset with an expired token answered %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestPasswordResetPolicyViolationKeepsToken(t *testing.T) {
+	pwh, mailer, u := newTestPasswordHandler(t)
+	resetToken := requestReset(t, pwh, mailer, u.Username)
+
+	for _, password := range []string{"short", "password"} {
+		rec := confirmReset(pwh, resetToken, password)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("reset to %q answered %d, want %d", password, rec.Code, http.StatusUnprocessableEntity)
+		}
+	}
+
+	rec := confirmReset(pwh, resetToken, "a brand new passphrase")
+	if rec.Code != http.StatusOK {
+		t.Errorf("reset after policy violations answered %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestPasswordResetRevokesSessions(t *testing.T) {
+	pwh, mailer, u := newTestPasswordHandler(t)
+	ctx := context.Background()
+
+	sessions := make([]*session.Session, 0, 2)
+	for i := 0; i < 2; i++ {
+		sess, err := pwh.SessionManager.Create(ctx, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, sess)
+	}
+
+	resetToken := requestReset(t, pwh, mailer, u.Username)
+	rec := confirmReset(pwh, resetToken, "a brand new passphrase")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset answered %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	for _, sess := range sessions {
+		_, err := pwh.SessionManager.UsernameBySessionID(ctx, sess.ID)
+		if !errors.Is(err, session.ErrSessionNotFound) {
+			t.Errorf("session after reset: got error %v, want %v", err, session.ErrSessionNotFound)
+		}
+
+		_, err = pwh.SessionManager.Refresh(ctx, sess.RefreshToken)
+		if !errors.Is(err, session.ErrRefreshTokenInvalid) {
+			t.Errorf("refresh after reset: got error %v, want %v", err, session.ErrRefreshTokenInvalid)
+		}
+	}
+}
+
+func TestPasswordResetUnknownUserSendsNothing(t *testing.T) {
+	pwh, mailer, _ := newTestPasswordHandler(t)
+
+	rec := httptest.NewRecorder()
+	body := `{"username":"nobody"}`
+	pwh.RequestReset(rec, httptest.NewRequest(http.MethodPost, "/api/password/reset", strings.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("reset request for an unknown user answered %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if len(mailer.Messages()) != 0 {
+		t.Errorf("%d messages sent for an unknown user, want none", len(mailer.Messages()))
+	}
+}
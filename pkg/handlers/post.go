@@ -3,12 +3,15 @@ package handlers
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/audit"
 	postpkg "github.com/teatah/rclone/pkg/post"
 	"github.com/teatah/rclone/pkg/responses"
 	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/token"
 
 	"github.com/teatah/rclone/pkg/user"
 
@@ -20,6 +23,7 @@ type PostHandler struct {
 	Logger         *zap.SugaredLogger
 	PostRepo       postpkg.PostRepo
 	UserRepo       user.UserRepo
+	Audit          *audit.Recorder
 }
 
 func (ph *PostHandler) Posts(w http.ResponseWriter, r *http.Request) {
@@ -50,9 +54,8 @@ func (ph *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	user, err := ph.UserRepo.UserByID(ctx, sess.UserID)
+	user, err := ph.author(rc, sess.UserID)
 	if err != nil {
-		rc.HandleError(err)
 		return
 	}
 	newPost, err := ph.PostRepo.CreatePost(ctx, user, postRequest)
@@ -71,6 +74,22 @@ func (ph *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID := vars["postID"]
 
+	ctx := r.Context()
+	post, err := ph.PostRepo.ViewPost(ctx, postID, ph.viewer(ctx, r))
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(post)
+}
+
+func (ph *PostHandler) PreviewPost(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: ph.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	postID := vars["postID"]
+
 	post, err := ph.PostRepo.Post(r.Context(), postID)
 	if err != nil {
 		rc.HandleError(err)
@@ -80,6 +99,25 @@ func (ph *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 	rc.WriteRawDataToBody(post)
 }
 
+// viewer identifies who is viewing a post: the user for authorized requests
+// and the client IP otherwise.
+func (ph *PostHandler) viewer(ctx context.Context, r *http.Request) string {
+	tokenString := token.TokenFromHeader(r)
+	if len(tokenString) != 0 {
+		sess, err := ph.SessionManager.Check(ctx, tokenString)
+		if err == nil {
+			return "user:" + sess.UserID
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
 func (ph *PostHandler) PostsByCategory(w http.ResponseWriter, r *http.Request) {
 	rc := &responses.ResponseContext{Logger: ph.Logger, Writer: w, Request: r}
 
@@ -126,13 +164,12 @@ func (ph *PostHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	user, err := ph.UserRepo.UserByID(ctx, sess.UserID)
+	user, err := ph.author(rc, sess.UserID)
 	if err != nil {
-		rc.HandleError(err)
 		return
 	}
 
-	modifiedPost, err := ph.PostRepo.CreateComment(ctx, postID, commentRequest.Comment, user)
+	modifiedPost, err := ph.PostRepo.CreateComment(ctx, postID, commentRequest, user)
 	if err != nil {
 		rc.HandleError(err)
 		return
@@ -162,12 +199,17 @@ func (ph *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	modifiedPost, err := ph.PostRepo.DeleteComment(ctx, postID, commentID, username)
+	modifiedPost, deletedComment, err := ph.PostRepo.DeleteComment(ctx, postID, commentID, username)
 	if err != nil {
 		rc.HandleError(err)
 		return
 	}
 
+	entry := audit.NewEntry(audit.ActionCommentDelete, audit.TargetComment, commentID)
+	entry.ActorID, entry.Actor = sess.UserID, username
+	entry.Before = audit.Snapshot(deletedComment)
+	ph.Audit.Record(ctx, entry)
+
 	rc.WriteRawDataToBody(modifiedPost)
 }
 
@@ -214,6 +256,57 @@ func (ph *PostHandler) vote(ctx context.Context, rc *responses.ResponseContext,
 	}
 
 	modifiedPost, err := ph.PostRepo.Vote(ctx, postID, sess.UserID, voteVal)
+	if err != nil {
+		return nil, err
+	}
+
+	return modifiedPost, nil
+}
+
+func (ph *PostHandler) CommentUpvote(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: ph.Logger, Writer: w, Request: r}
+
+	modifiedPost, err := ph.voteComment(r.Context(), rc, postpkg.Upvote)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+	rc.WriteRawDataToBody(modifiedPost)
+}
+
+func (ph *PostHandler) CommentDownvote(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: ph.Logger, Writer: w, Request: r}
+
+	modifiedPost, err := ph.voteComment(r.Context(), rc, postpkg.Downnvote)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+	rc.WriteRawDataToBody(modifiedPost)
+}
+
+func (ph *PostHandler) CommentUnvote(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: ph.Logger, Writer: w, Request: r}
+
+	modifiedPost, err := ph.voteComment(r.Context(), rc, postpkg.Unvote)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+	rc.WriteRawDataToBody(modifiedPost)
+}
+
+func (ph *PostHandler) voteComment(ctx context.Context, rc *responses.ResponseContext, voteVal int) (*postpkg.Post, error) {
+	vars := mux.Vars(rc.Request)
+	postID := vars["postID"]
+	commentID := vars["commentID"]
+
+	sess, err := SessionFromContext(rc.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedPost, err := ph.PostRepo.VoteComment(ctx, postID, commentID, sess.UserID, voteVal)
 
 	return modifiedPost, err
 }
@@ -224,12 +317,30 @@ func (ph *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID := vars["postID"]
 
-	err := ph.PostRepo.DeletePost(r.Context(), postID)
+	sess, err := SessionFromContext(r)
 	if err != nil {
 		rc.HandleError(err)
 		return
 	}
 
+	ctx := r.Context()
+	username, err := ph.SessionManager.UsernameBySessionID(ctx, sess.ID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	deletedPost, err := ph.PostRepo.DeletePost(ctx, postID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	entry := audit.NewEntry(audit.ActionPostDelete, audit.TargetPost, postID)
+	entry.ActorID, entry.Actor = sess.UserID, username
+	entry.Before = audit.Snapshot(deletedPost)
+	ph.Audit.Record(ctx, entry)
+
 	rc.WriteRawDataToBody(responses.Message{Message: "success"})
 }
 
@@ -248,6 +359,38 @@ func (ph *PostHandler) PostsByUser(w http.ResponseWriter, r *http.Request) {
 	rc.WriteRawDataToBody(posts)
 }
 
+func (ph *PostHandler) CommentsByUser(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: ph.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	offset, limit, respErr := pageFromQuery(r)
+	if respErr != nil {
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	sort := r.URL.Query().Get("sort")
+	switch sort {
+	case "":
+		sort = postpkg.SortNew
+	case postpkg.SortNew, postpkg.SortOld, postpkg.SortTop:
+	default:
+		respErr = responses.NewResponseError("query", "sort", sort, "must be one of new, old, top")
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	comments, err := ph.PostRepo.CommentsByUser(r.Context(), username, sort, offset, limit)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(comments)
+}
+
 func SessionFromContext(r *http.Request) (*session.Session, error) {
 	sessVal := session.SessionCtxValue("session")
 	ctxSess := r.Context().Value(sessVal)
@@ -258,3 +401,26 @@ func SessionFromContext(r *http.Request) (*session.Session, error) {
 
 	return sess, nil
 }
+
+// author returns the user if they are allowed to post. Otherwise it answers
+// the request and returns the error.
+func (ph *PostHandler) author(rc *responses.ResponseContext, userID string) (*user.User, error) {
+	u, err := ph.UserRepo.UserByID(rc.Request.Context(), userID)
+	if err != nil {
+		rc.HandleError(err)
+		return nil, err
+	}
+
+	err = ph.UserRepo.CanPost(u)
+	if err == user.ErrEmailNotVerified {
+		respErr := responses.NewResponseError("body", "email", u.Email, err.Error())
+		rc.JSONError(http.StatusForbidden, respErr)
+		return nil, err
+	}
+	if err != nil {
+		rc.HandleError(err)
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/responses"
+	"github.com/teatah/rclone/pkg/session"
+	userpkg "github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+type SessionHandler struct {
+	Logger         *zap.SugaredLogger
+	SessionManager *session.DBSessionManager
+	UserRepo       userpkg.UserRepo
+	Audit          *audit.Recorder
+}
+
+// Logout ends the caller's session together with its refresh token.
+func (sh *SessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: sh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	ctx := r.Context()
+	err = sh.SessionManager.Logout(ctx, sess)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	entry := audit.NewEntry(audit.ActionLogout, audit.TargetSession, sess.FamilyID)
+	sh.record(rc, sess, entry)
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+// Sessions lists the caller's active sessions.
+func (sh *SessionHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: sh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	sessions, err := sh.SessionManager.Sessions(r.Context(), sess.UserID, sess)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(sessions)
+}
+
+// Revoke ends one of the caller's sessions.
+func (sh *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: sh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	sessionID := vars["sessionID"]
+
+	ctx := r.Context()
+	err = sh.SessionManager.Revoke(ctx, sess.UserID, sessionID)
+	if err != nil {
+		if err == session.ErrSessionNotFound {
+			respErr := responses.NewResponseError("url", "sessionID", sessionID, err.Error())
+			rc.JSONError(http.StatusNotFound, respErr)
+			return
+		}
+		rc.HandleError(err)
+		return
+	}
+
+	entry := audit.NewEntry(audit.ActionSessionRevoke, audit.TargetSession, sessionID)
+	sh.record(rc, sess, entry)
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+// RevokeOthers ends all of the caller's sessions except the current one.
+func (sh *SessionHandler) RevokeOthers(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: sh.Logger, Writer: w, Request: r}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	ctx := r.Context()
+	revoked, err := sh.SessionManager.RevokeOthers(ctx, sess.UserID, sess)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	if revoked != 0 {
+		entry := audit.NewEntry(audit.ActionSessionRevoke, audit.TargetUser, sess.UserID)
+		entry.After = audit.Snapshot(map[string]int64{"revoked": revoked})
+		sh.record(rc, sess, entry)
+	}
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+// record stores the audit entry on behalf of the owner of sess.
+func (sh *SessionHandler) record(rc *responses.ResponseContext, sess *session.Session, entry *audit.Entry) {
+	ctx := rc.Request.Context()
+
+	u, err := sh.UserRepo.UserByID(ctx, sess.UserID)
+	if err != nil {
+		rc.LogError(err)
+		return
+	}
+
+	entry.ActorID, entry.Actor = u.ID, u.Username
+	sh.Audit.Record(ctx, entry)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/broker"
+	"github.com/teatah/rclone/pkg/responses"
+	"go.uber.org/zap"
+)
+
+// DefaultStreamHeartbeat is used when StreamHandler.Heartbeat is not set.
+const DefaultStreamHeartbeat = 30 * time.Second
+
+type StreamHandler struct {
+	Logger    *zap.SugaredLogger
+	Broker    *broker.Broker
+	Heartbeat time.Duration
+}
+
+func (sh *StreamHandler) PostEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sh.stream(w, r, broker.PostTopic(vars["postID"]))
+}
+
+func (sh *StreamHandler) CategoryEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sh.stream(w, r, broker.CategoryTopic(vars["category"]))
+}
+
+// stream sends the events of the topic as Server-Sent Events until the client
+// disconnects or falls too far behind.
+func (sh *StreamHandler) stream(w http.ResponseWriter, r *http.Request, topic string) {
+	rc := &responses.ResponseContext{Logger: sh.Logger, Writer: w, Request: r}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		rc.HandleError(errors.New("streaming is not supported by the response writer"))
+		return
+	}
+
+	sub := sh.Broker.Subscribe(topic)
+	defer sh.Broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	interval := sh.Heartbeat
+	if interval <= 0 {
+		interval = DefaultStreamHeartbeat
+	}
+
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": ping\n\n")
+			if err != nil {
+				return
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				_, _ = fmt.Fprint(w, "event: lagged\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+
+			data, err := responses.ToJSON(e)
+			if err != nil {
+				rc.LogError(err)
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			if err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
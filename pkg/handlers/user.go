@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
+	"unicode/utf8"
 
+	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/audit"
+	postpkg "github.com/teatah/rclone/pkg/post"
 	"github.com/teatah/rclone/pkg/responses"
 	"github.com/teatah/rclone/pkg/session"
 	userpkg "github.com/teatah/rclone/pkg/user"
@@ -13,6 +19,9 @@ type UserHandler struct {
 	SessionManager *session.DBSessionManager
 	Logger         *zap.SugaredLogger
 	UserRepo       userpkg.UserRepo
+	PostRepo       postpkg.PostRepo
+	Audit          *audit.Recorder
+	EmailVerifier  *EmailVerifier
 }
 
 func (uh *UserHandler) GetLogger() *zap.SugaredLogger {
@@ -32,15 +41,30 @@ func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user, err := uh.UserRepo.Register(ctx, userRequest)
 	if err != nil {
-		if err == userpkg.ErrUserAlreadyExists {
+		var validationErr *userpkg.ValidationError
+		switch {
+		case err == userpkg.ErrUserAlreadyExists:
 			respErr := responses.NewResponseError("body", "username", "username", err.Error())
 			rc.JSONError(http.StatusUnprocessableEntity, respErr)
-			return
+		case errors.As(err, &validationErr):
+			rc.JSONError(http.StatusUnprocessableEntity, fieldErrors(validationErr)...)
+		default:
+			rc.HandleError(err)
 		}
-		rc.HandleError(err)
 		return
 	}
 
+	entry := audit.NewEntry(audit.ActionRegister, audit.TargetUser, user.ID)
+	entry.ActorID, entry.Actor = user.ID, user.Username
+	uh.Audit.Record(ctx, entry)
+
+	if len(user.Email) != 0 {
+		err = uh.EmailVerifier.Send(ctx, user)
+		if err != nil {
+			rc.LogError(err)
+		}
+	}
+
 	sess, err := uh.SessionManager.Create(ctx, user)
 	if err != nil {
 		rc.HandleError(err)
@@ -49,7 +73,7 @@ func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusAccepted)
 
-	bodyResponse := responses.TokenResponse{Token: sess.ID}
+	bodyResponse := responses.TokenResponse{Token: sess.ID, RefreshToken: sess.RefreshToken}
 	rc.WriteRawDataToBody(bodyResponse)
 }
 
@@ -68,18 +92,195 @@ func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		rc.LogError(err)
 
+		entry := audit.NewEntry(audit.ActionLoginFailed, audit.TargetUser, userRequest.Username)
+		entry.Actor = userRequest.Username
+		uh.Audit.Record(ctx, entry)
+
 		w.WriteHeader(http.StatusUnauthorized)
 		rc.WriteRawDataToBody(responses.Message{Message: err.Error()})
 
 		return
 	}
 
+	entry := audit.NewEntry(audit.ActionLogin, audit.TargetUser, user.ID)
+	entry.ActorID, entry.Actor = user.ID, user.Username
+	uh.Audit.Record(ctx, entry)
+
 	sess, err := uh.SessionManager.Create(ctx, user)
 	if err != nil {
 		rc.HandleError(err)
 		return
 	}
 
-	bodyResponse := responses.TokenResponse{Token: sess.ID}
+	bodyResponse := responses.TokenResponse{Token: sess.ID, RefreshToken: sess.RefreshToken}
 	rc.WriteRawDataToBody(bodyResponse)
 }
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// Presenting a refresh token twice revokes all sessions descending from the
+// same login.
+func (uh *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: uh.Logger, Writer: w, Request: r}
+
+	refreshRequest := &session.RefreshRequest{}
+	err := responses.ReadBody(r, refreshRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	if len(refreshRequest.RefreshToken) == 0 {
+		respErr := responses.NewResponseError("body", "refreshToken", "", "refresh token is required")
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	ctx := r.Context()
+	sess, err := uh.SessionManager.Refresh(ctx, refreshRequest.RefreshToken)
+
+	var reuseErr *session.RefreshReuseError
+	switch {
+	case errors.As(err, &reuseErr):
+		entry := audit.NewEntry(audit.ActionRefreshReuse, audit.TargetUser, reuseErr.UserID)
+		entry.ActorID = reuseErr.UserID
+		entry.After = audit.Snapshot(reuseErr)
+		uh.Audit.Record(ctx, entry)
+
+		fallthrough
+	case errors.Is(err, session.ErrRefreshTokenInvalid), errors.Is(err, userpkg.ErrUserBanned):
+		rc.LogError(err)
+
+		w.WriteHeader(http.StatusUnauthorized)
+		rc.WriteRawDataToBody(responses.Message{Message: err.Error()})
+
+		return
+	case err != nil:
+		rc.HandleError(err)
+		return
+	}
+
+	bodyResponse := responses.TokenResponse{Token: sess.ID, RefreshToken: sess.RefreshToken}
+	rc.WriteRawDataToBody(bodyResponse)
+}
+
+func (uh *UserHandler) Karma(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: uh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	user, err := uh.UserRepo.UserByName(r.Context(), username)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(user.Karma)
+}
+
+func (uh *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: uh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	ctx := r.Context()
+	user, err := uh.UserRepo.UserByName(ctx, username)
+	if err != nil {
+		if err == userpkg.ErrUserNotFound {
+			respErr := responses.NewResponseError("url", "username", username, err.Error())
+			rc.JSONError(http.StatusNotFound, respErr)
+			return
+		}
+		rc.HandleError(err)
+		return
+	}
+
+	profile := user.Profile()
+
+	profile.PostCount, profile.CommentCount, err = uh.PostRepo.CountsByAuthor(ctx, user.ID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(profile)
+}
+
+func (uh *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: uh.Logger, Writer: w, Request: r}
+
+	profileRequest := &userpkg.ProfileRequest{}
+	err := responses.ReadBody(r, profileRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	respErrs := validateProfile(profileRequest)
+	if len(respErrs) != 0 {
+		rc.JSONError(http.StatusUnprocessableEntity, respErrs...)
+		return
+	}
+
+	sess, err := SessionFromContext(r)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := uh.UserRepo.UpdateProfile(ctx, sess.UserID, profileRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	profile := user.Profile()
+
+	profile.PostCount, profile.CommentCount, err = uh.PostRepo.CountsByAuthor(ctx, user.ID)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(profile)
+}
+
+// fieldErrors lists the fields rejected by the user policy as body errors.
+func fieldErrors(validationErr *userpkg.ValidationError) []*responses.ResponseError {
+	respErrs := make([]*responses.ResponseError, 0, len(validationErr.Errors))
+	for _, fe := range validationErr.Errors {
+		respErrs = append(respErrs, responses.NewResponseError("body", fe.Field, fe.Value, fe.Msg))
+	}
+
+	return respErrs
+}
+
+func validateProfile(pr *userpkg.ProfileRequest) []*responses.ResponseError {
+	var respErrs []*responses.ResponseError
+
+	if pr.DisplayName != nil && utf8.RuneCountInString(*pr.DisplayName) > userpkg.MaxDisplayNameLen {
+		respErrs = append(respErrs,
+			responses.NewResponseError("body", "displayName", *pr.DisplayName, "is too long"))
+	}
+
+	if pr.Bio != nil && utf8.RuneCountInString(*pr.Bio) > userpkg.MaxBioLen {
+		respErrs = append(respErrs,
+			responses.NewResponseError("body", "bio", "", "is too long"))
+	}
+
+	if pr.Avatar != nil && len(*pr.Avatar) != 0 {
+		avatarURL, err := url.Parse(*pr.Avatar)
+		switch {
+		case len(*pr.Avatar) > userpkg.MaxAvatarLen:
+			respErrs = append(respErrs,
+				responses.NewResponseError("body", "avatar", "", "is too long"))
+		case err != nil || (avatarURL.Scheme != "http" && avatarURL.Scheme != "https") || avatarURL.Host == "":
+			respErrs = append(respErrs,
+				responses.NewResponseError("body", "avatar", *pr.Avatar, "must be an http or https URL"))
+		}
+	}
+
+	return respErrs
+}
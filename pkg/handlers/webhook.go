@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/audit"
+	"github.com/teatah/rclone/pkg/responses"
+	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/webhook"
+	"go.uber.org/zap"
+)
+
+type WebhookHandler struct {
+	SessionManager *session.DBSessionManager
+	Logger         *zap.SugaredLogger
+	WebhookRepo    webhook.WebhookRepo
+	Audit          *audit.Recorder
+}
+
+func (wh *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: wh.Logger, Writer: w, Request: r}
+
+	webhookRequest := &webhook.WebhookRequest{}
+	err := responses.ReadBody(r, webhookRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	respErrs := validateWebhook(webhookRequest)
+	if len(respErrs) != 0 {
+		rc.JSONError(http.StatusUnprocessableEntity, respErrs...)
+		return
+	}
+
+	newWebhook, err := webhook.NewWebhook(webhookRequest)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	ctx := r.Context()
+	err = wh.WebhookRepo.Create(ctx, newWebhook)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	entry := audit.NewEntry(audit.ActionWebhookCreate, audit.TargetWebhook, newWebhook.ID)
+	entry.After = audit.Snapshot(newWebhook)
+	wh.record(rc, entry)
+
+	w.WriteHeader(http.StatusCreated)
+	rc.WriteRawDataToBody(&webhook.CreatedWebhook{Webhook: newWebhook, Secret: newWebhook.Secret})
+}
+
+func (wh *WebhookHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: wh.Logger, Writer: w, Request: r}
+
+	webhooks, err := wh.WebhookRepo.Webhooks(r.Context())
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(webhooks)
+}
+
+func (wh *WebhookHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: wh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	webhookID := vars["webhookID"]
+
+	found, err := wh.WebhookRepo.Webhook(r.Context(), webhookID)
+	if err != nil {
+		wh.handleWebhookError(rc, webhookID, err)
+		return
+	}
+
+	rc.WriteRawDataToBody(found)
+}
+
+func (wh *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: wh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	webhookID := vars["webhookID"]
+
+	ctx := r.Context()
+	deletedWebhook, err := wh.WebhookRepo.Webhook(ctx, webhookID)
+	if err != nil {
+		wh.handleWebhookError(rc, webhookID, err)
+		return
+	}
+
+	err = wh.WebhookRepo.Delete(ctx, webhookID)
+	if err != nil {
+		wh.handleWebhookError(rc, webhookID, err)
+		return
+	}
+
+	entry := audit.NewEntry(audit.ActionWebhookDelete, audit.TargetWebhook, webhookID)
+	entry.Before = audit.Snapshot(deletedWebhook)
+	wh.record(rc, entry)
+
+	rc.WriteRawDataToBody(responses.Message{Message: "success"})
+}
+
+func (wh *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	rc := &responses.ResponseContext{Logger: wh.Logger, Writer: w, Request: r}
+
+	vars := mux.Vars(r)
+	webhookID := vars["webhookID"]
+
+	offset, limit, respErr := pageFromQuery(r)
+	if respErr != nil {
+		rc.JSONError(http.StatusUnprocessableEntity, respErr)
+		return
+	}
+
+	ctx := r.Context()
+	_, err := wh.WebhookRepo.Webhook(ctx, webhookID)
+	if err != nil {
+		wh.handleWebhookError(rc, webhookID, err)
+		return
+	}
+
+	deliveries, err := wh.WebhookRepo.Deliveries(ctx, webhookID, offset, limit)
+	if err != nil {
+		rc.HandleError(err)
+		return
+	}
+
+	rc.WriteRawDataToBody(deliveries)
+}
+
+// record stores the audit entry on behalf of the admin of the request.
+func (wh *WebhookHandler) record(rc *responses.ResponseContext, entry *audit.Entry) {
+	ctx := rc.Request.Context()
+
+	sess, err := SessionFromContext(rc.Request)
+	if err != nil {
+		rc.LogError(err)
+		return
+	}
+
+	username, err := wh.SessionManager.UsernameBySessionID(ctx, sess.ID)
+	if err != nil {
+		rc.LogError(err)
+		return
+	}
+
+	entry.ActorID, entry.Actor = sess.UserID, username
+	wh.Audit.Record(ctx, entry)
+}
+
+func (wh *WebhookHandler) handleWebhookError(rc *responses.ResponseContext, webhookID string, err error) {
+	if err == webhook.ErrWebhookNotFound {
+		respErr := responses.NewResponseError("url", "webhookID", webhookID, err.Error())
+		rc.JSONError(http.StatusNotFound, respErr)
+		return
+	}
+
+	rc.HandleError(err)
+}
+
+func validateWebhook(wr *webhook.WebhookRequest) []*responses.ResponseError {
+	var respErrs []*responses.ResponseError
+
+	u, err := url.Parse(wr.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		respErrs = append(respErrs,
+			responses.NewResponseError("body", "url", wr.URL, "must be an http or https URL"))
+	}
+
+	if len(wr.Events) == 0 {
+		respErrs = append(respErrs, responses.NewResponseError("body", "events", "", "is required"))
+	}
+	for _, event := range wr.Events {
+		if !webhook.IsValidEvent(event) {
+			respErrs = append(respErrs, responses.NewResponseError("body", "events", event, "unknown event type"))
+		}
+	}
+
+	return respErrs
+}
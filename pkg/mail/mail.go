@@ -0,0 +1,54 @@
+// Package mail sends email to users through a Mailer.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"time"
+)
+
+// Message is a plain-text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// bytes renders the message with its headers, sent from the given address.
+func (m *Message) bytes(from string) ([]byte, error) {
+	_, err := netmail.ParseAddress(m.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", m.To, err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	_, err = w.Write([]byte(m.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// memoryLimit is how many messages a MemoryMailer keeps.
+const memoryLimit = 100
+
+// MemoryMailer keeps the messages it is given instead of sending them, for
+// tests and development. Only the latest messages are kept.
+type MemoryMailer struct {
+	mu       *sync.Mutex
+	messages []*Message
+	// Logger, when set, logs every message, so links sent to users can be
+	// followed without a mail server.
+	Logger *zap.SugaredLogger
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{
+		mu: &sync.Mutex{},
+	}
+}
+
+func (mm *MemoryMailer) Send(_ context.Context, msg *Message) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.messages = append(mm.messages, msg)
+	if len(mm.messages) > memoryLimit {
+		mm.messages = mm.messages[len(mm.messages)-memoryLimit:]
+	}
+
+	if mm.Logger != nil {
+		mm.Logger.Infow("mail", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	}
+
+	return nil
+}
+
+// Messages returns the kept messages, oldest first.
+func (mm *MemoryMailer) Messages() []*Message {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	return append([]*Message(nil), mm.messages...)
+}
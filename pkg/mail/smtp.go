@@ -0,0 +1,117 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+)
+
+const DefaultSMTPTimeout = 10 * time.Second
+
+// SMTPMailer sends messages through an SMTP server, upgrading the connection
+// with STARTTLS when the server offers it.
+type SMTPMailer struct {
+	host string
+	port string
+	from string
+	auth smtp.Auth
+	// Timeout bounds the delivery of a single message.
+	Timeout time.Duration
+}
+
+// NewSMTPMailer makes a mailer sending from the given address, which may
+// include a display name. Without a username it does not authenticate.
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	sm := &SMTPMailer{
+		host:    host,
+		port:    port,
+		from:    from,
+		Timeout: DefaultSMTPTimeout,
+	}
+	if len(username) != 0 {
+		sm.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return sm
+}
+
+func (sm *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	sender, err := netmail.ParseAddress(sm.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", sm.from, err)
+	}
+
+	data, err := msg.bytes(sender.String())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sm.Timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(sm.host, sm.port))
+	if err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, sm.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	ok, _ := c.Extension("STARTTLS")
+	if ok {
+		err = c.StartTLS(&tls.Config{ServerName: sm.host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if sm.auth != nil {
+		err = c.Auth(sm.auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(sender.Address)
+	if err != nil {
+		return err
+	}
+
+	err = c.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
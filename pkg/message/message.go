@@ -0,0 +1,78 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const MaxMessageLen = 10000
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrBlocked              = errors.New("user does not accept messages from you")
+	ErrSelfMessage          = errors.New("cannot send a message to yourself")
+)
+
+type MessageRequest struct {
+	Message string `json:"message"`
+}
+
+type Participant struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type Conversation struct {
+	ID          string      `json:"id"`
+	With        Participant `json:"with"`
+	LastMessage string      `json:"lastMessage"`
+	Unread      int         `json:"unread"`
+	Updated     time.Time   `json:"updated"`
+}
+
+type Message struct {
+	ID             string     `json:"id"`
+	ConversationID string     `json:"conversationId"`
+	SenderID       string     `json:"senderId"`
+	Body           string     `json:"body"`
+	Created        time.Time  `json:"created"`
+	ReadAt         *time.Time `json:"readAt"`
+}
+
+type UnreadCount struct {
+	Unread int `json:"unread"`
+}
+
+type MessageRepo interface {
+	Send(ctx context.Context, senderID string, recipientID string, body string) (*Message, error)
+	Conversations(ctx context.Context, userID string, offset int, limit int) (*[]Conversation, error)
+	Messages(ctx context.Context, userID string, conversationID string, offset int, limit int) (*[]Message, error)
+	MarkRead(ctx context.Context, userID string, conversationID string) error
+	UnreadCount(ctx context.Context, userID string) (int, error)
+	Block(ctx context.Context, userID string, blockedID string) error
+	Unblock(ctx context.Context, userID string, blockedID string) error
+	Blocked(ctx context.Context, userID string) (*[]Participant, error)
+}
+
+func NewMessage(conversationID string, senderID string, body string) *Message {
+	return &Message{
+		ID:             uuid.NewString(),
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+		Created:        time.Now().UTC(),
+	}
+}
+
+// conversationUsers orders the pair of users so that a conversation between
+// them is stored once regardless of who started it.
+func conversationUsers(a string, b string) (string, string) {
+	if a < b {
+		return a, b
+	}
+
+	return b, a
+}
@@ -0,0 +1,261 @@
+package message
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type MessageDBRepo struct {
+	pgPool *pgxpool.Pool
+}
+
+func NewMessageDBRepo(pgPool *pgxpool.Pool) *MessageDBRepo {
+	return &MessageDBRepo{
+		pgPool: pgPool,
+	}
+}
+
+func (mr *MessageDBRepo) Send(ctx context.Context, senderID string, recipientID string, body string) (*Message, error) {
+	if senderID == recipientID {
+		return nil, ErrSelfMessage
+	}
+
+	var blocked bool
+	err := mr.pgPool.QueryRow(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM blocks WHERE user_id = $1 AND blocked_id = $2)",
+		recipientID,
+		senderID,
+	).Scan(&blocked)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+
+	tx, err := mr.pgPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	userA, userB := conversationUsers(senderID, recipientID)
+
+	var conversationID string
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO conversations (id, user_a, user_b, updated)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_a, user_b) DO UPDATE SET updated = NOW()
+		RETURNING id`,
+		uuid.NewString(),
+		userA,
+		userB,
+	).Scan(&conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := NewMessage(conversationID, senderID, body)
+
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO messages (id, conversation_id, sender_id, body, created) VALUES ($1, $2, $3, $4, $5)",
+		msg.ID,
+		msg.ConversationID,
+		msg.SenderID,
+		msg.Body,
+		msg.Created,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, tx.Commit(ctx)
+}
+
+func (mr *MessageDBRepo) Conversations(ctx context.Context, userID string, offset int, limit int) (*[]Conversation, error) {
+	rows, err := mr.pgPool.Query(
+		ctx,
+		`SELECT c.id, u.id, u.username, c.updated,
+			COALESCE((SELECT m.body FROM messages m
+				WHERE m.conversation_id = c.id
+				ORDER BY m.created DESC LIMIT 1), ''),
+			(SELECT COUNT(*) FROM messages m
+				WHERE m.conversation_id = c.id AND m.sender_id <> $1 AND m.read_at IS NULL)
+		FROM conversations c
+		INNER JOIN users u ON u.id = CASE WHEN c.user_a = $1 THEN c.user_b ELSE c.user_a END
+		WHERE c.user_a = $1 OR c.user_b = $1
+		ORDER BY c.updated DESC
+		LIMIT $2 OFFSET $3`,
+		userID,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := make([]Conversation, 0, limit)
+	for rows.Next() {
+		var c Conversation
+		err = rows.Scan(&c.ID, &c.With.ID, &c.With.Username, &c.Updated, &c.LastMessage, &c.Unread)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, c)
+	}
+
+	return &conversations, rows.Err()
+}
+
+func (mr *MessageDBRepo) Messages(
+	ctx context.Context,
+	userID string,
+	conversationID string,
+	offset int,
+	limit int,
+) (*[]Message, error) {
+	err := mr.checkParticipant(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mr.pgPool.Query(
+		ctx,
+		`SELECT id, conversation_id, sender_id, body, created, read_at
+		FROM messages
+		WHERE conversation_id = $1
+		ORDER BY created DESC
+		LIMIT $2 OFFSET $3`,
+		conversationID,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]Message, 0, limit)
+	for rows.Next() {
+		var m Message
+		err = rows.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.Body, &m.Created, &m.ReadAt)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return &messages, rows.Err()
+}
+
+// MarkRead marks every message the user received in the conversation as read.
+func (mr *MessageDBRepo) MarkRead(ctx context.Context, userID string, conversationID string) error {
+	err := mr.checkParticipant(ctx, userID, conversationID)
+	if err != nil {
+		return err
+	}
+
+	_, err = mr.pgPool.Exec(
+		ctx,
+		`UPDATE messages SET read_at = NOW()
+		WHERE conversation_id = $1 AND sender_id <> $2 AND read_at IS NULL`,
+		conversationID,
+		userID,
+	)
+
+	return err
+}
+
+func (mr *MessageDBRepo) UnreadCount(ctx context.Context, userID string) (int, error) {
+	var unread int
+	err := mr.pgPool.QueryRow(
+		ctx,
+		`SELECT COUNT(*)
+		FROM messages m
+		INNER JOIN conversations c ON c.id = m.conversation_id
+		WHERE (c.user_a = $1 OR c.user_b = $1) AND m.sender_id <> $1 AND m.read_at IS NULL`,
+		userID,
+	).Scan(&unread)
+
+	return unread, err
+}
+
+func (mr *MessageDBRepo) Block(ctx context.Context, userID string, blockedID string) error {
+	if userID == blockedID {
+		return ErrSelfMessage
+	}
+
+	_, err := mr.pgPool.Exec(
+		ctx,
+		"INSERT INTO blocks (user_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		userID,
+		blockedID,
+	)
+
+	return err
+}
+
+func (mr *MessageDBRepo) Unblock(ctx context.Context, userID string, blockedID string) error {
+	_, err := mr.pgPool.Exec(
+		ctx,
+		"DELETE FROM blocks WHERE user_id = $1 AND blocked_id = $2",
+		userID,
+		blockedID,
+	)
+
+	return err
+}
+
+func (mr *MessageDBRepo) Blocked(ctx context.Context, userID string) (*[]Participant, error) {
+	rows, err := mr.pgPool.Query(
+		ctx,
+		`SELECT u.id, u.username
+		FROM blocks b
+		INNER JOIN users u ON u.id = b.blocked_id
+		WHERE b.user_id = $1
+		ORDER BY b.created DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := make([]Participant, 0)
+	for rows.Next() {
+		var p Participant
+		err = rows.Scan(&p.ID, &p.Username)
+		if err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, p)
+	}
+
+	return &blocked, rows.Err()
+}
+
+func (mr *MessageDBRepo) checkParticipant(ctx context.Context, userID string, conversationID string) error {
+	var isParticipant bool
+	err := mr.pgPool.QueryRow(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM conversations WHERE id = $1 AND (user_a = $2 OR user_b = $2))",
+		conversationID,
+		userID,
+	).Scan(&isParticipant)
+	if err != nil {
+		return err
+	}
+	if !isParticipant {
+		return ErrConversationNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/teatah/rclone/pkg/responses"
+	"github.com/teatah/rclone/pkg/session"
+	"github.com/teatah/rclone/pkg/user"
+	"go.uber.org/zap"
+)
+
+// AdminMiddleware lets through only the users whose names are listed in admins.
+// It must be wrapped by AuthMiddleware.
+func AdminMiddleware(
+	admins []string,
+	ur user.UserRepo,
+	lgr *zap.SugaredLogger,
+	next http.Handler) http.Handler {
+	adminSet := make(map[string]bool, len(admins))
+	for _, admin := range admins {
+		adminSet[admin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := &responses.ResponseContext{Logger: lgr, Writer: w, Request: r}
+
+		sess, ok := r.Context().Value(session.SessionCtxValue("session")).(*session.Session)
+		if !ok {
+			rc.HandleError(errors.New("failed to get session from context"))
+			return
+		}
+
+		u, err := ur.UserByID(r.Context(), sess.UserID)
+		if err != nil {
+			rc.HandleError(err)
+			return
+		}
+
+		if !adminSet[u.Username] {
+			respErr := responses.NewResponseError("header", "authorization", "", "admin access required")
+			rc.JSONError(http.StatusForbidden, respErr)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
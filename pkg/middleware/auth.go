@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/teatah/rclone/pkg/responses"
@@ -10,6 +12,8 @@ import (
 	"go.uber.org/zap"
 )
 
+const authRealm = "rclone"
+
 func AuthMiddleware(
 	sm session.SessionManager,
 	lgr *zap.SugaredLogger,
@@ -20,6 +24,13 @@ func AuthMiddleware(
 		sess, err := sm.Check(r.Context(), tokenString)
 		if err != nil {
 			rc := &responses.ResponseContext{Logger: lgr, Writer: w, Request: r}
+
+			var validationErr *token.ValidationError
+			if errors.As(err, &validationErr) || errors.Is(err, session.ErrSessionNotFound) {
+				unauthorized(rc, err)
+				return
+			}
+
 			rc.HandleError(err)
 
 			return
@@ -34,3 +45,16 @@ func AuthMiddleware(
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// unauthorized rejects the request with 401 and a Bearer challenge as
+// described in RFC 6750. A request without a token gets no error code.
+func unauthorized(rc *responses.ResponseContext, err error) {
+	challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+	if !errors.Is(err, token.ErrTokenMissing) {
+		challenge += fmt.Sprintf(", error=\"invalid_token\", error_description=%q", err.Error())
+	}
+	rc.Writer.Header().Set("WWW-Authenticate", challenge)
+
+	respErr := responses.NewResponseError("header", "Authorization", "", err.Error())
+	rc.JSONError(http.StatusUnauthorized, respErr)
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/teatah/rclone/pkg/audit"
+)
+
+// ClientMiddleware stores the client IP and user agent of the request for the
+// audit log.
+func ClientMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := &audit.Client{
+			IP:        ClientIP(r),
+			UserAgent: r.UserAgent(),
+		}
+
+		next.ServeHTTP(w, r.WithContext(audit.WithClient(r.Context(), client)))
+	})
+}
+
+// ClientIP returns the host part of the remote address of the request.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
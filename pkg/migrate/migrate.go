@@ -0,0 +1,73 @@
+// Package migrate keeps the Postgres schema and the Mongo collections and
+// indexes at the version the code expects.
+//
+// Postgres migrations are the numbered NNNN_name.up.sql and NNNN_name.down.sql
+// files in the postgres directory; Mongo migrations are listed in
+// MongoMigrations. Applied versions are recorded in the schema_migrations
+// table and collection respectively. Migrations must be idempotent, so that
+// databases created before the migrations existed can adopt them.
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// Status describes one migration. Applied is nil for pending migrations.
+type Status struct {
+	Version int
+	Name    string
+	Applied *time.Time
+}
+
+type Migrator interface {
+	// Up applies all pending migrations and returns their versions.
+	Up(ctx context.Context) ([]int, error)
+	// Down reverts the last steps applied migrations and returns their
+	// versions.
+	Down(ctx context.Context, steps int) ([]int, error)
+	Status(ctx context.Context) ([]Status, error)
+}
+
+// Named pairs a migrator with the name of its database.
+type Named struct {
+	Name     string
+	Migrator Migrator
+}
+
+// All returns the migrators of both databases, Postgres first.
+func All(pgPool *pgxpool.Pool, db *mongo.Database) ([]Named, error) {
+	pgMigrator, err := NewPGMigrator(pgPool)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Named{
+		{Name: "postgres", Migrator: pgMigrator},
+		{Name: "mongo", Migrator: NewMongoMigrator(db)},
+	}, nil
+}
+
+// UpAll applies the pending migrations of both databases and logs them.
+func UpAll(ctx context.Context, pgPool *pgxpool.Pool, db *mongo.Database, logger *zap.SugaredLogger) error {
+	migrators, err := All(pgPool, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrators {
+		applied, err := m.Migrator.Up(ctx)
+		for _, version := range applied {
+			logger.Infof("applied %s migration %04d", m.Name, version)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoMigration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+type mongoVersion struct {
+	Version int       `bson:"_id"`
+	Name    string    `bson:"name"`
+	Applied time.Time `bson:"applied"`
+}
+
+type MongoMigrator struct {
+	db         *mongo.Database
+	versions   *mongo.Collection
+	migrations []MongoMigration
+}
+
+func NewMongoMigrator(db *mongo.Database) *MongoMigrator {
+	return &MongoMigrator{
+		db:         db,
+		versions:   db.Collection("schema_migrations"),
+		migrations: MongoMigrations,
+	}
+}
+
+func (mm *MongoMigrator) Up(ctx context.Context) ([]int, error) {
+	versions, err := mm.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []int
+	for _, m := range mm.migrations {
+		if _, ok := versions[m.Version]; ok {
+			continue
+		}
+
+		err = m.Up(ctx, mm.db)
+		if err != nil {
+			return applied, fmt.Errorf("failed to apply mongo migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		record := &mongoVersion{Version: m.Version, Name: m.Name, Applied: time.Now().UTC()}
+		opt := options.Replace().SetUpsert(true)
+		_, err = mm.versions.ReplaceOne(ctx, bson.M{"_id": m.Version}, record, opt)
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.Version)
+	}
+
+	return applied, nil
+}
+
+func (mm *MongoMigrator) Down(ctx context.Context, steps int) ([]int, error) {
+	versions, err := mm.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []int
+	for i := len(mm.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := mm.migrations[i]
+		if _, ok := versions[m.Version]; !ok {
+			continue
+		}
+
+		err = m.Down(ctx, mm.db)
+		if err != nil {
+			return reverted, fmt.Errorf("failed to revert mongo migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		_, err = mm.versions.DeleteOne(ctx, bson.M{"_id": m.Version})
+		if err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, m.Version)
+	}
+
+	return reverted, nil
+}
+
+func (mm *MongoMigrator) Status(ctx context.Context) ([]Status, error) {
+	versions, err := mm.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(mm.migrations))
+	for _, m := range mm.migrations {
+		status := Status{Version: m.Version, Name: m.Name}
+		if applied, ok := versions[m.Version]; ok {
+			status.Applied = &applied
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (mm *MongoMigrator) appliedVersions(ctx context.Context) (map[int]time.Time, error) {
+	cur, err := mm.versions.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []mongoVersion
+	err = cur.All(ctx, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int]time.Time, len(records))
+	for _, r := range records {
+		versions[r.Version] = r.Applied
+	}
+
+	return versions, nil
+}
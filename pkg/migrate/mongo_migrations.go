@@ -0,0 +1,142 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/teatah/rclone/pkg/databases/mongodb"
+	"github.com/teatah/rclone/pkg/outbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoMigrations lists the Mongo migrations in version order. Append new
+// migrations at the end and never change released ones.
+var MongoMigrations = []MongoMigration{
+	{
+		Version: 1,
+		Name:    "posts_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return setIndexes(ctx, db.Collection("posts"), "votes.user", "comments.author.username")
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("posts"), "votes.user", "comments.author.username")
+		},
+	},
+	{
+		Version: 2,
+		Name:    "views_ttl",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return mongodb.SetTTLIndex(ctx, db.Collection("views"), "created", 24*time.Hour)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return mongodb.DropIndex(ctx, db.Collection("views"), "created")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "notifications_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			notifications := db.Collection("notifications")
+
+			err := mongodb.SetIndex(ctx, notifications, "userId")
+			if err != nil {
+				return err
+			}
+
+			return mongodb.SetUniqueIndex(ctx, notifications, "key")
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("notifications"), "userId", "key")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "webhook_deliveries_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return mongodb.SetIndex(ctx, db.Collection("webhook_deliveries"), "nextAttempt")
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return mongodb.DropIndex(ctx, db.Collection("webhook_deliveries"), "nextAttempt")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "posts_outbox_index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return mongodb.SetIndex(ctx, db.Collection("posts"), "outbox.id")
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return mongodb.DropIndex(ctx, db.Collection("posts"), "outbox.id")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "deleted_posts_index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return mongodb.SetIndex(ctx, db.Collection("deleted_posts"), "deleted")
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return mongodb.DropIndex(ctx, db.Collection("deleted_posts"), "deleted")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "posts_outbox_pending",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			posts := db.Collection("posts")
+
+			// Flag the posts with events and make their events due at once.
+			_, err := posts.UpdateMany(
+				ctx,
+				bson.M{outbox.MongoField + ".0": bson.M{"$exists": true}},
+				mongo.Pipeline{{{Key: "$set", Value: bson.M{
+					outbox.MongoFlag: true,
+					outbox.MongoField: bson.M{"$map": bson.M{
+						"input": "$" + outbox.MongoField,
+						"in":    bson.M{"$mergeObjects": bson.A{bson.M{"nextAttempt": "$$this.created"}, "$$this"}},
+					}},
+				}}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			return mongodb.SetIndex(ctx, posts, outbox.MongoFlag)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			posts := db.Collection("posts")
+
+			err := mongodb.DropIndex(ctx, posts, outbox.MongoFlag)
+			if err != nil {
+				return err
+			}
+
+			_, err = posts.UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{outbox.MongoFlag: ""}})
+
+			return err
+		},
+	},
+}
+
+func setIndexes(ctx context.Context, col *mongo.Collection, fields ...string) error {
+	for _, field := range fields {
+		err := mongodb.SetIndex(ctx, col, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dropIndexes(ctx context.Context, col *mongo.Collection, fields ...string) error {
+	for _, field := range fields {
+		err := mongodb.DropIndex(ctx, col, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed postgres/*.sql
+var postgresFiles embed.FS
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// pgLockID is the key of the advisory lock held while migrating, so that
+// instances starting together don't migrate concurrently.
+const pgLockID = 7_242_614
+
+type pgMigration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+type PGMigrator struct {
+	pgPool     *pgxpool.Pool
+	migrations []*pgMigration
+}
+
+func NewPGMigrator(pgPool *pgxpool.Pool) (*PGMigrator, error) {
+	migrations, err := loadPGMigrations(postgresFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PGMigrator{
+		pgPool:     pgPool,
+		migrations: migrations,
+	}, nil
+}
+
+func (pm *PGMigrator) Up(ctx context.Context) ([]int, error) {
+	var applied []int
+
+	err := pm.withLock(ctx, func(conn *pgxpool.Conn) error {
+		versions, err := appliedPGVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range pm.migrations {
+			if _, ok := versions[m.version]; ok {
+				continue
+			}
+
+			err = pm.apply(ctx, conn, m.up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name)
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %w", m.version, m.name, err)
+			}
+			applied = append(applied, m.version)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+func (pm *PGMigrator) Down(ctx context.Context, steps int) ([]int, error) {
+	var reverted []int
+
+	err := pm.withLock(ctx, func(conn *pgxpool.Conn) error {
+		versions, err := appliedPGVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(pm.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := pm.migrations[i]
+			if _, ok := versions[m.version]; !ok {
+				continue
+			}
+
+			err = pm.apply(ctx, conn, m.down,
+				"DELETE FROM schema_migrations WHERE version = $1", m.version)
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %04d_%s: %w", m.version, m.name, err)
+			}
+			reverted = append(reverted, m.version)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+func (pm *PGMigrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := pm.withLock(ctx, func(conn *pgxpool.Conn) error {
+		versions, err := appliedPGVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range pm.migrations {
+			status := Status{Version: m.version, Name: m.name}
+			if applied, ok := versions[m.version]; ok {
+				status.Applied = &applied
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// apply runs the migration script and the version bookkeeping statement in
+// one transaction.
+func (pm *PGMigrator) apply(ctx context.Context, conn *pgxpool.Conn, script string, record string, args ...any) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, script)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, record, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// withLock runs f on a connection holding the migration lock, creating the
+// schema_migrations table first if needed.
+func (pm *PGMigrator) withLock(ctx context.Context, f func(conn *pgxpool.Conn) error) error {
+	conn, err := pm.pgPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", pgLockID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", pgLockID)
+	}()
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	return f(conn)
+}
+
+func appliedPGVersions(ctx context.Context, conn *pgxpool.Conn) (map[int]time.Time, error) {
+	rows, err := conn.Query(ctx, "SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int]time.Time)
+	var version int
+	var applied time.Time
+	_, err = pgx.ForEachRow(rows, []any{&version, &applied}, func() error {
+		versions[version] = applied
+		return nil
+	})
+
+	return versions, err
+}
+
+func loadPGMigrations(files fs.FS) ([]*pgMigration, error) {
+	entries, err := fs.ReadDir(files, "postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*pgMigration)
+	for _, entry := range entries {
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &pgMigration{version: version, name: match[2]}
+			byVersion[version] = m
+		}
+		if m.name != match[2] {
+			return nil, fmt.Errorf("migration %04d has names %s and %s", version, m.name, match[2])
+		}
+
+		script, err := fs.ReadFile(files, path.Join("postgres", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			m.up = string(script)
+		} else {
+			m.down = string(script)
+		}
+	}
+
+	migrations := make([]*pgMigration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(m.up) == 0 || len(m.down) == 0 {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down scripts", m.version, m.name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(55) PRIMARY KEY,
+    username VARCHAR(55) NOT NULL UNIQUE,
+    password BYTEA NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(55) NOT NULL,
+    expires_at INTEGER NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Databases created from the old build/_sql/init.sql already have both
+-- tables, so the statements above leave them alone. Add what that schema
+-- lacks.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created TIMESTAMPTZ NOT NULL DEFAULT NOW();
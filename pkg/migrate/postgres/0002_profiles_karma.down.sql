@@ -0,0 +1,6 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS display_name,
+    DROP COLUMN IF EXISTS bio,
+    DROP COLUMN IF EXISTS avatar,
+    DROP COLUMN IF EXISTS post_karma,
+    DROP COLUMN IF EXISTS comment_karma;
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS display_name VARCHAR(55) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS bio VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS avatar VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS post_karma INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS comment_karma INTEGER NOT NULL DEFAULT 0;
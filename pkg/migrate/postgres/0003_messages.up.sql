@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS conversations (
+    id VARCHAR(55) PRIMARY KEY,
+    user_a VARCHAR(55) NOT NULL,
+    user_b VARCHAR(55) NOT NULL,
+    updated TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_a, user_b),
+    FOREIGN KEY (user_a) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_b) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id VARCHAR(55) PRIMARY KEY,
+    conversation_id VARCHAR(55) NOT NULL,
+    sender_id VARCHAR(55) NOT NULL,
+    body TEXT NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    read_at TIMESTAMPTZ,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS messages_conversation_created_idx ON messages (conversation_id, created);
+
+CREATE TABLE IF NOT EXISTS blocks (
+    user_id VARCHAR(55) NOT NULL,
+    blocked_id VARCHAR(55) NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, blocked_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
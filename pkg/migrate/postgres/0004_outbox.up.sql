@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id VARCHAR(55) PRIMARY KEY,
+    type VARCHAR(55) NOT NULL,
+    payload JSONB NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS outbox_created_idx ON outbox (created);
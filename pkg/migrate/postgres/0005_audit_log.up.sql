@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id VARCHAR(55) NOT NULL DEFAULT '',
+    actor TEXT NOT NULL,
+    action VARCHAR(55) NOT NULL,
+    target_type VARCHAR(55) NOT NULL,
+    target_id TEXT NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_idx ON audit_log (created);
+CREATE INDEX IF NOT EXISTS audit_log_actor_created_idx ON audit_log (actor, created);
+CREATE INDEX IF NOT EXISTS audit_log_target_created_idx ON audit_log (target_type, target_id, created);
+
+-- The audit log is append-only.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,2 @@
+DELETE FROM sessions WHERE LENGTH(id) > 255;
+ALTER TABLE sessions ALTER COLUMN id TYPE VARCHAR(255);
@@ -0,0 +1,2 @@
+-- Tokens signed with RSA keys are longer than 255 characters.
+ALTER TABLE sessions ALTER COLUMN id TYPE TEXT;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS sessions_family_id_idx;
+ALTER TABLE sessions DROP COLUMN IF EXISTS family_id;
+DROP TABLE IF EXISTS refresh_tokens;
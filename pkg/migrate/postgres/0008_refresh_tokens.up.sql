@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id VARCHAR(55) PRIMARY KEY,
+    family_id VARCHAR(55) NOT NULL,
+    user_id VARCHAR(55) NOT NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+-- Sessions started by a login and its refreshes share the family of their
+-- refresh tokens, so a reused refresh token ends all of them.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS family_id VARCHAR(55);
+CREATE INDEX IF NOT EXISTS sessions_family_id_idx ON sessions (family_id);
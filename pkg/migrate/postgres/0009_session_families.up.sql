@@ -0,0 +1,20 @@
+-- A session family is one login as the user sees it: the sessions and
+-- refresh tokens descending from it share its id.
+CREATE TABLE IF NOT EXISTS session_families (
+    id VARCHAR(55) PRIMARY KEY,
+    user_id VARCHAR(55) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS session_families_user_id_idx ON session_families (user_id);
+
+INSERT INTO session_families (id, user_id)
+SELECT family_id, MIN(user_id)
+FROM refresh_tokens
+WHERE revoked_at IS NULL
+GROUP BY family_id
+ON CONFLICT DO NOTHING;
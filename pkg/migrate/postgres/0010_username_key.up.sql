@@ -0,0 +1,17 @@
+-- username_key is the username folded to lower case and NFKC-normalized; it
+-- keeps usernames unique regardless of case and of how they are encoded.
+-- Registration computes it in Go. Existing users get the closest SQL
+-- equivalent, and only the oldest of those sharing a key gets one, so
+-- accounts created before the check keep working.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username_key VARCHAR(255);
+
+UPDATE users SET username_key = ranked.key
+FROM (
+    SELECT id, LOWER(NORMALIZE(username, NFKC)) AS key,
+        ROW_NUMBER() OVER (PARTITION BY LOWER(NORMALIZE(username, NFKC)) ORDER BY created, id) AS n
+    FROM users
+) ranked
+WHERE users.id = ranked.id AND ranked.n = 1 AND users.username_key IS NULL
+    AND NOT EXISTS (SELECT 1 FROM users taken WHERE taken.username_key = ranked.key);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_key_idx ON users (username_key);
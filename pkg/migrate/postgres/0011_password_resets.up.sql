@@ -0,0 +1,15 @@
+-- email is where password reset links are sent to.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(255);
+
+-- A password reset token works once and only its SHA-256 hash is kept.
+CREATE TABLE IF NOT EXISTS password_resets (
+    id VARCHAR(55) PRIMARY KEY,
+    user_id VARCHAR(55) NOT NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS password_resets_user_id_idx ON password_resets (user_id);
@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+-- email_verification_sent_at throttles how often verification links are sent.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verification_sent_at TIMESTAMPTZ;
+
+-- Until now addresses could only be set by administrators, who vouch for
+-- them.
+UPDATE users SET email_verified_at = NOW()
+WHERE email IS NOT NULL AND email_verified_at IS NULL;
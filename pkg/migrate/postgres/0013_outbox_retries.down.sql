@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS outbox_pending_idx;
+ALTER TABLE outbox
+    DROP COLUMN IF EXISTS failed_at,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS next_attempt,
+    DROP COLUMN IF EXISTS attempts;
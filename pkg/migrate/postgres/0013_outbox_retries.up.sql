@@ -0,0 +1,11 @@
+-- Failed events wait for next_attempt. After their last attempt they get
+-- failed_at and are no longer dispatched, but kept for inspection.
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS next_attempt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS last_error TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+UPDATE outbox SET next_attempt = created;
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt) WHERE failed_at IS NULL;
@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/teatah/rclone/pkg/user"
+)
+
+// baselineSchema is the schema build/_sql/init.sql created before there were
+// migrations.
+const baselineSchema = `
+CREATE TABLE users (
+    id VARCHAR(55) PRIMARY KEY,
+    username VARCHAR(55) NOT NULL UNIQUE,
+    password BYTEA NOT NULL
+);
+
+CREATE TABLE sessions (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(55) NOT NULL,
+    expires_at INTEGER NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);`
+
+// testPool connects to the database in TEST_POSTGRES_URL with a schema of its
+// own, which is dropped when the test ends.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	connString := os.Getenv("TEST_POSTGRES_URL")
+	if len(connString) == 0 {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+
+	admin, err := pgxpool.New(ctx, connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolConfig.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func TestPGMigratorUpgradesBaselineSchema(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+
+	_, err := pool.Exec(ctx, baselineSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pool.Exec(ctx, "INSERT INTO users (id, username, password) VALUES ('1', 'alice', 'hash')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := NewPGMigrator(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("migrating the baseline schema: %s", err)
+	}
+	if len(applied) != len(migrator.migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrator.migrations))
+	}
+
+	userRepo := user.NewUserDBRepo(pool)
+	u, err := userRepo.UserByName(ctx, "alice")
+	if err != nil {
+		t.Fatalf("reading a baseline user: %s", err)
+	}
+	if u.Created.IsZero() {
+		t.Error("baseline user has no creation time")
+	}
+
+	_, err = userRepo.Users(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("listing users: %s", err)
+	}
+}
+
+func TestPGMigratorDownAndUp(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+
+	migrator, err := NewPGMigrator(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := migrator.Down(ctx, len(migrator.migrations))
+	if err != nil {
+		t.Fatalf("reverting all migrations: %s", err)
+	}
+	if len(reverted) != len(migrator.migrations) {
+		t.Fatalf("reverted %d migrations, want %d", len(reverted), len(migrator.migrations))
+	}
+
+	_, err = migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("migrating again: %s", err)
+	}
+}
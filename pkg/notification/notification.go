@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TypeComment       = "comment"
+	TypeReply         = "reply"
+	TypeMention       = "mention"
+	TypeVoteMilestone = "vote_milestone"
+)
+
+// Types lists every notification type a user can mute.
+var Types = []string{TypeComment, TypeReply, TypeMention, TypeVoteMilestone}
+
+// VoteMilestones are the post scores that notify the author when reached.
+var VoteMilestones = []int{10, 50, 100, 500, 1000, 5000, 10000}
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type Actor struct {
+	Username string `json:"username" bson:"username"`
+	ID       string `json:"id" bson:"id"`
+}
+
+type Notification struct {
+	BSONID    primitive.ObjectID `json:"-" bson:"_id"`
+	ID        string             `json:"id" bson:"id"`
+	UserID    string             `json:"-" bson:"userId"`
+	Type      string             `json:"type" bson:"type"`
+	Actor     *Actor             `json:"actor,omitempty" bson:"actor,omitempty"`
+	PostID    string             `json:"postId" bson:"postId"`
+	PostTitle string             `json:"postTitle,omitempty" bson:"postTitle,omitempty"`
+	CommentID string             `json:"commentId,omitempty" bson:"commentId,omitempty"`
+	Milestone int                `json:"milestone,omitempty" bson:"milestone,omitempty"`
+	Read      bool               `json:"read" bson:"read"`
+	Created   time.Time          `json:"created" bson:"created"`
+	// Key identifies what the notification is about, so that redelivered
+	// events and repeated milestones do not notify twice.
+	Key string `json:"-" bson:"key,omitempty"`
+}
+
+// Settings are per-user notification preferences.
+type Settings struct {
+	UserID string   `json:"-" bson:"_id"`
+	Muted  []string `json:"muted" bson:"muted"`
+}
+
+type UnreadCount struct {
+	Unread int `json:"unread"`
+}
+
+type NotificationRepo interface {
+	Add(ctx context.Context, n *Notification) error
+	Notifications(ctx context.Context, userID string, unreadOnly bool, offset int, limit int) (*[]Notification, error)
+	UnreadCount(ctx context.Context, userID string) (int, error)
+	MarkRead(ctx context.Context, userID string, notificationID string) error
+	MarkAllRead(ctx context.Context, userID string) error
+	Settings(ctx context.Context, userID string) (*Settings, error)
+	UpdateSettings(ctx context.Context, s *Settings) error
+}
+
+func NewNotification(userID string, notificationType string, actor *Actor, postID string) *Notification {
+	bsonID := primitive.NewObjectID()
+
+	return &Notification{
+		BSONID:  bsonID,
+		ID:      bsonID.Hex(),
+		UserID:  userID,
+		Type:    notificationType,
+		Actor:   actor,
+		PostID:  postID,
+		Created: time.Now().UTC(),
+	}
+}
+
+func (s *Settings) IsMuted(notificationType string) bool {
+	for _, muted := range s.Muted {
+		if muted == notificationType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func IsValidType(notificationType string) bool {
+	for _, t := range Types {
+		if t == notificationType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/teatah/rclone/pkg/post"
+	"github.com/teatah/rclone/pkg/user"
+)
+
+var mentionRe = regexp.MustCompile(`(?:^|[^\w@])@([\w-]+)`)
+
+// Notifier creates notifications for the users affected by forum activity.
+type Notifier struct {
+	repo     NotificationRepo
+	userRepo user.UserRepo
+}
+
+func NewNotifier(repo NotificationRepo, userRepo user.UserRepo) *Notifier {
+	return &Notifier{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// PostCreated notifies the users mentioned in the post text.
+func (n *Notifier) PostCreated(ctx context.Context, p *post.Post) error {
+	actor := &Actor{Username: p.Author.Username, ID: p.Author.ID}
+	notified := map[string]bool{p.Author.ID: true}
+
+	return n.notifyMentions(ctx, p.Text, actor, p, "", notified)
+}
+
+// CommentCreated notifies the post author, the author of the parent comment
+// and the users mentioned in the comment. Every user gets at most one
+// notification per comment.
+func (n *Notifier) CommentCreated(ctx context.Context, p *post.Post, c *post.Comment) error {
+	actor := &Actor{Username: c.Author.Username, ID: c.Author.ID}
+	notified := map[string]bool{c.Author.ID: true}
+
+	if len(c.ParentID) != 0 {
+		for _, parent := range p.Comments {
+			if parent.ID != c.ParentID || notified[parent.Author.ID] {
+				continue
+			}
+
+			err := n.notify(ctx, parent.Author.ID, TypeReply, actor, p, c.ID, notified)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !notified[p.Author.ID] {
+		err := n.notify(ctx, p.Author.ID, TypeComment, actor, p, c.ID, notified)
+		if err != nil {
+			return err
+		}
+	}
+
+	return n.notifyMentions(ctx, c.Body, actor, p, c.ID, notified)
+}
+
+// VoteCast notifies the post author of every milestone in VoteMilestones that
+// a vote raising the post score from oldScore to score has reached. Each
+// milestone is reported once per post.
+func (n *Notifier) VoteCast(ctx context.Context, p *post.Post, oldScore, score int) error {
+	reached := make([]int, 0)
+	for _, milestone := range VoteMilestones {
+		if milestone > oldScore && milestone <= score {
+			reached = append(reached, milestone)
+		}
+	}
+	if len(reached) == 0 {
+		return nil
+	}
+
+	settings, err := n.repo.Settings(ctx, p.Author.ID)
+	if err != nil {
+		return err
+	}
+	if settings.IsMuted(TypeVoteMilestone) {
+		return nil
+	}
+
+	for _, milestone := range reached {
+		notification := NewNotification(p.Author.ID, TypeVoteMilestone, nil, p.ID)
+		notification.PostTitle = p.Title
+		notification.Milestone = milestone
+		notification.Key = fmt.Sprintf("%s:%s:%d", TypeVoteMilestone, p.ID, milestone)
+
+		err := n.repo.Add(ctx, notification)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *Notifier) notifyMentions(
+	ctx context.Context,
+	text string,
+	actor *Actor,
+	p *post.Post,
+	commentID string,
+	notified map[string]bool,
+) error {
+	for _, match := range mentionRe.FindAllStringSubmatch(text, -1) {
+		mentioned, err := n.userRepo.UserByName(ctx, match[1])
+		if err == user.ErrUserNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if notified[mentioned.ID] {
+			continue
+		}
+
+		err = n.notify(ctx, mentioned.ID, TypeMention, actor, p, commentID, notified)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *Notifier) notify(
+	ctx context.Context,
+	userID string,
+	notificationType string,
+	actor *Actor,
+	p *post.Post,
+	commentID string,
+	notified map[string]bool,
+) error {
+	notified[userID] = true
+
+	settings, err := n.repo.Settings(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if settings.IsMuted(notificationType) {
+		return nil
+	}
+
+	notification := NewNotification(userID, notificationType, actor, p.ID)
+	notification.PostTitle = p.Title
+	notification.CommentID = commentID
+	// Events are delivered at least once; the key makes a redelivery add
+	// nothing.
+	notification.Key = fmt.Sprintf("%s:%s:%s:%s", notificationType, p.ID, commentID, userID)
+
+	return n.repo.Add(ctx, notification)
+}
@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NotificationDBRepo struct {
+	notificationsColl *mongo.Collection
+	settingsColl      *mongo.Collection
+}
+
+func NewNotificationDBRepo(notificationsCollection *mongo.Collection, settingsCollection *mongo.Collection) *NotificationDBRepo {
+	return &NotificationDBRepo{
+		notificationsColl: notificationsCollection,
+		settingsColl:      settingsCollection,
+	}
+}
+
+// Add stores the notification. A notification whose Key was already used is
+// silently dropped.
+func (nr *NotificationDBRepo) Add(ctx context.Context, n *Notification) error {
+	_, err := nr.notificationsColl.InsertOne(ctx, n)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (nr *NotificationDBRepo) Notifications(
+	ctx context.Context,
+	userID string,
+	unreadOnly bool,
+	offset int,
+	limit int,
+) (*[]Notification, error) {
+	filter := bson.M{"userId": userID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+
+	opt := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	notifications := make([]Notification, 0, limit)
+	cur, err := nr.notificationsColl.Find(ctx, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.All(ctx, &notifications)
+
+	return &notifications, err
+}
+
+func (nr *NotificationDBRepo) UnreadCount(ctx context.Context, userID string) (int, error) {
+	count, err := nr.notificationsColl.CountDocuments(ctx, bson.M{"userId": userID, "read": false})
+
+	return int(count), err
+}
+
+func (nr *NotificationDBRepo) MarkRead(ctx context.Context, userID string, notificationID string) error {
+	bsonID, err := primitive.ObjectIDFromHex(notificationID)
+	if err != nil {
+		return ErrNotificationNotFound
+	}
+
+	filter := bson.M{"_id": bsonID, "userId": userID}
+	update := bson.M{"$set": bson.M{"read": true}}
+
+	res, err := nr.notificationsColl.UpdateOne(ctx, filter, update)
+	if err == nil && res.MatchedCount == 0 {
+		err = ErrNotificationNotFound
+	}
+
+	return err
+}
+
+func (nr *NotificationDBRepo) MarkAllRead(ctx context.Context, userID string) error {
+	filter := bson.M{"userId": userID, "read": false}
+	update := bson.M{"$set": bson.M{"read": true}}
+
+	_, err := nr.notificationsColl.UpdateMany(ctx, filter, update)
+
+	return err
+}
+
+func (nr *NotificationDBRepo) Settings(ctx context.Context, userID string) (*Settings, error) {
+	settings := &Settings{}
+
+	err := nr.settingsColl.FindOne(ctx, bson.M{"_id": userID}).Decode(settings)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &Settings{UserID: userID, Muted: make([]string, 0)}, nil
+	}
+
+	return settings, err
+}
+
+func (nr *NotificationDBRepo) UpdateSettings(ctx context.Context, s *Settings) error {
+	opt := options.Replace().SetUpsert(true)
+
+	_, err := nr.settingsColl.ReplaceOne(ctx, bson.M{"_id": s.UserID}, s, opt)
+
+	return err
+}
@@ -0,0 +1,69 @@
+package notification
+
+import (
+	"context"
+	"errors"
+
+	"github.com/teatah/rclone/pkg/outbox"
+	"github.com/teatah/rclone/pkg/post"
+)
+
+// Subscriber creates notifications from the outbox events of posts. Events of
+// posts and comments deleted in the meantime are skipped.
+type Subscriber struct {
+	Notifier *Notifier
+	PostRepo post.PostRepo
+}
+
+func (s *Subscriber) Register(d *outbox.Dispatcher) {
+	d.Subscribe(outbox.PostCreated, s.postCreated)
+	d.Subscribe(outbox.CommentCreated, s.commentCreated)
+	d.Subscribe(outbox.VoteCast, s.voteCast)
+}
+
+func (s *Subscriber) postCreated(ctx context.Context, e *outbox.Event) error {
+	p, err := s.post(ctx, e.PostID)
+	if p == nil {
+		return err
+	}
+
+	return s.Notifier.PostCreated(ctx, p)
+}
+
+func (s *Subscriber) commentCreated(ctx context.Context, e *outbox.Event) error {
+	p, err := s.post(ctx, e.PostID)
+	if p == nil {
+		return err
+	}
+
+	for _, c := range p.Comments {
+		if c.ID == e.CommentID {
+			return s.Notifier.CommentCreated(ctx, p, c)
+		}
+	}
+
+	return nil
+}
+
+func (s *Subscriber) voteCast(ctx context.Context, e *outbox.Event) error {
+	if len(e.CommentID) != 0 {
+		return nil
+	}
+
+	p, err := s.post(ctx, e.PostID)
+	if p == nil {
+		return err
+	}
+
+	return s.Notifier.VoteCast(ctx, p, e.OldScore, e.Score)
+}
+
+// post returns nil and no error if the post no longer exists.
+func (s *Subscriber) post(ctx context.Context, postID string) (*post.Post, error) {
+	p, err := s.PostRepo.Post(ctx, postID)
+	if errors.Is(err, post.ErrPostNotFound) {
+		return nil, nil
+	}
+
+	return p, err
+}
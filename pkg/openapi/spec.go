@@ -0,0 +1,107 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+type Spec struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+
+	raw []byte
+}
+
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	AllOf                []*Schema          `json:"allOf"`
+	Enum                 []any              `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Nullable             bool               `json:"nullable"`
+}
+
+// Load parses the OpenAPI document embedded into the binary.
+func Load() (*Spec, error) {
+	spec := &Spec{raw: specJSON}
+
+	err := json.Unmarshal(specJSON, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// Operation returns the operation of the route with the given mux path
+// template, or nil if the document does not describe it.
+func (s *Spec) Operation(pathTemplate string, method string) *Operation {
+	pathItem, ok := s.Paths[pathTemplate]
+	if !ok {
+		return nil
+	}
+
+	return pathItem[strings.ToLower(method)]
+}
+
+// ServeHTTP serves the OpenAPI document.
+func (s *Spec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(s.raw)
+}
+
+func (s *Spec) resolveSchema(schema *Schema) *Schema {
+	for schema != nil && len(schema.Ref) != 0 {
+		schema = s.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+
+	return schema
+}
+
+func (s *Spec) resolveParameter(param *Parameter) *Parameter {
+	for param != nil && len(param.Ref) != 0 {
+		param = s.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+	}
+
+	return param
+}
@@ -0,0 +1,282 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/teatah/rclone/pkg/responses"
+	"go.uber.org/zap"
+)
+
+// ValidationMiddleware rejects requests whose query parameters or JSON body do
+// not match the operation described in the spec. It must be installed with
+// mux.Router.Use so that the matched route is known.
+func ValidationMiddleware(spec *Spec, lgr *zap.SugaredLogger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			pathTemplate, err := route.GetPathTemplate()
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			operation := spec.Operation(pathTemplate, r.Method)
+			if operation == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rc := &responses.ResponseContext{Logger: lgr, Writer: w, Request: r}
+
+			respErrs, err := spec.ValidateRequest(operation, r)
+			if err != nil {
+				rc.HandleError(err)
+				return
+			}
+			if len(respErrs) != 0 {
+				rc.JSONError(http.StatusUnprocessableEntity, respErrs...)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ValidateRequest checks the query parameters and the JSON body of the request.
+// The body is restored so that handlers can read it again.
+func (s *Spec) ValidateRequest(operation *Operation, r *http.Request) ([]*responses.ResponseError, error) {
+	var respErrs []*responses.ResponseError
+
+	query := r.URL.Query()
+	for _, param := range operation.Parameters {
+		param = s.resolveParameter(param)
+		if param == nil || param.In != "query" {
+			continue
+		}
+
+		value, ok := query[param.Name]
+		if !ok {
+			if param.Required {
+				respErrs = append(respErrs, responses.NewResponseError("query", param.Name, "", "is required"))
+			}
+			continue
+		}
+
+		respErrs = append(respErrs, s.validateQueryValue(param, value[0])...)
+	}
+
+	if operation.RequestBody == nil {
+		return respErrs, nil
+	}
+
+	mediaType, ok := operation.RequestBody.Content["application/json"]
+	if !ok {
+		return respErrs, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if operation.RequestBody.Required {
+			respErrs = append(respErrs, responses.NewResponseError("body", "body", "", "is required"))
+		}
+		return respErrs, nil
+	}
+
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	err = decoder.Decode(&value)
+	if err != nil {
+		respErrs = append(respErrs, responses.NewResponseError("body", "body", "", "is not valid JSON"))
+		return respErrs, nil
+	}
+
+	respErrs = append(respErrs, s.validateValue(mediaType.Schema, value, "")...)
+
+	return respErrs, nil
+}
+
+func (s *Spec) validateQueryValue(param *Parameter, value string) []*responses.ResponseError {
+	schema := s.resolveSchema(param.Schema)
+	if schema == nil {
+		return nil
+	}
+
+	var typed any = value
+	switch schema.Type {
+	case "integer", "number":
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return []*responses.ResponseError{
+				responses.NewResponseError("query", param.Name, value, "must be a number"),
+			}
+		}
+		typed = json.Number(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return []*responses.ResponseError{
+				responses.NewResponseError("query", param.Name, value, "must be a boolean"),
+			}
+		}
+		typed = b
+	}
+
+	respErrs := s.validateValue(schema, typed, param.Name)
+	for _, respErr := range respErrs {
+		respErr.Location = "query"
+	}
+
+	return respErrs
+}
+
+func (s *Spec) validateValue(schema *Schema, value any, param string) []*responses.ResponseError {
+	schema = s.resolveSchema(schema)
+	if schema == nil {
+		return nil
+	}
+
+	var respErrs []*responses.ResponseError
+	for _, sub := range schema.AllOf {
+		respErrs = append(respErrs, s.validateValue(sub, value, param)...)
+	}
+
+	if value == nil {
+		if schema.Nullable || len(schema.Type) == 0 {
+			return respErrs
+		}
+		return append(respErrs, bodyError(param, "", "must not be null"))
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return append(respErrs, bodyError(param, "", "must be an object"))
+		}
+		respErrs = append(respErrs, s.validateObject(schema, obj, param)...)
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return append(respErrs, bodyError(param, "", "must be an array"))
+		}
+		for i, item := range arr {
+			respErrs = append(respErrs, s.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", param, i))...)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return append(respErrs, bodyError(param, "", "must be a string"))
+		}
+		length := utf8.RuneCountInString(str)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			respErrs = append(respErrs, bodyError(param, str, fmt.Sprintf("must be at least %d characters", *schema.MinLength)))
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			respErrs = append(respErrs, bodyError(param, "", fmt.Sprintf("must be at most %d characters", *schema.MaxLength)))
+		}
+		if len(schema.Enum) != 0 && !inEnum(schema.Enum, str) {
+			respErrs = append(respErrs, bodyError(param, str, "is not one of the allowed values"))
+		}
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return append(respErrs, bodyError(param, "", "must be a number"))
+		}
+		f, err := num.Float64()
+		if err != nil || (schema.Type == "integer" && f != float64(int64(f))) {
+			return append(respErrs, bodyError(param, num.String(), "must be an integer"))
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			respErrs = append(respErrs, bodyError(param, num.String(), fmt.Sprintf("must be at least %v", *schema.Minimum)))
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			respErrs = append(respErrs, bodyError(param, num.String(), fmt.Sprintf("must be at most %v", *schema.Maximum)))
+		}
+		if len(schema.Enum) != 0 && !inEnum(schema.Enum, f) {
+			respErrs = append(respErrs, bodyError(param, num.String(), "is not one of the allowed values"))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return append(respErrs, bodyError(param, "", "must be a boolean"))
+		}
+	}
+
+	return respErrs
+}
+
+func (s *Spec) validateObject(schema *Schema, obj map[string]any, param string) []*responses.ResponseError {
+	var respErrs []*responses.ResponseError
+
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			respErrs = append(respErrs, bodyError(joinParam(param, name), "", "is required"))
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propSchema, ok := schema.Properties[name]
+		if !ok {
+			if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				respErrs = append(respErrs, bodyError(joinParam(param, name), "", "is not allowed"))
+			}
+			continue
+		}
+
+		respErrs = append(respErrs, s.validateValue(propSchema, obj[name], joinParam(param, name))...)
+	}
+
+	return respErrs
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, allowed := range enum {
+		if allowed == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func joinParam(parent string, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+func bodyError(param string, value string, msg string) *responses.ResponseError {
+	if len(param) == 0 {
+		param = "body"
+	}
+
+	return responses.NewResponseError("body", param, value, msg)
+}
@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultPollInterval = time.Second
+	DefaultBatchSize    = 100
+	DefaultMaxBackoff   = 5 * time.Minute
+	DefaultMaxAttempts  = 10
+)
+
+// Handler processes one event. Returning an error makes the Dispatcher
+// deliver the event again later.
+type Handler func(ctx context.Context, e *Event) error
+
+// Dispatcher delivers the events of its stores to the subscribed handlers at
+// least once. An event is removed from its store only after all of its
+// handlers succeed; failed events are retried with exponential backoff until
+// MaxAttempts deliveries have failed, and then marked failed.
+type Dispatcher struct {
+	stores       []Store
+	handlers     map[string][]Handler
+	logger       *zap.SugaredLogger
+	PollInterval time.Duration
+	BatchSize    int
+	MaxBackoff   time.Duration
+	MaxAttempts  int
+}
+
+func NewDispatcher(logger *zap.SugaredLogger, stores ...Store) *Dispatcher {
+	return &Dispatcher{
+		stores:       stores,
+		handlers:     make(map[string][]Handler),
+		logger:       logger,
+		PollInterval: DefaultPollInterval,
+		BatchSize:    DefaultBatchSize,
+		MaxBackoff:   DefaultMaxBackoff,
+		MaxAttempts:  DefaultMaxAttempts,
+	}
+}
+
+// Subscribe registers h for events of eventType. It must be called before Run.
+func (d *Dispatcher) Subscribe(eventType string, h Handler) {
+	d.handlers[eventType] = append(d.handlers[eventType], h)
+}
+
+// Run polls the stores and dispatches pending events until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, store := range d.stores {
+				err := d.dispatchPending(ctx, store)
+				if err != nil {
+					d.logger.Errorf("failed to dispatch outbox events: %s", err)
+				}
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchPending(ctx context.Context, store Store) error {
+	events, err := store.Pending(ctx, d.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		err = d.dispatch(ctx, e)
+		if err != nil {
+			err = d.fail(ctx, store, e, err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = store.Remove(ctx, e.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, e *Event) error {
+	for _, h := range d.handlers[e.Type] {
+		err := h(ctx, e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fail records a failed delivery of the event in its store, and marks the
+// event failed once it has used up its attempts.
+func (d *Dispatcher) fail(ctx context.Context, store Store, e *Event, dispatchErr error) error {
+	e.Attempts++
+	e.Error = dispatchErr.Error()
+
+	if e.Attempts >= d.MaxAttempts {
+		d.logger.Errorf("failed to handle %s event %s, giving up after %d attempts: %s",
+			e.Type, e.ID, e.Attempts, dispatchErr)
+
+		return store.Fail(ctx, e)
+	}
+
+	backoff := d.MaxBackoff
+	if e.Attempts < 32 && d.PollInterval<<e.Attempts < d.MaxBackoff {
+		backoff = d.PollInterval << e.Attempts
+	}
+	e.NextAttempt = time.Now().UTC().Add(backoff)
+
+	d.logger.Errorf("failed to handle %s event %s (attempt %d, retry in %s): %s",
+		e.Type, e.ID, e.Attempts, backoff, dispatchErr)
+
+	return store.Retry(ctx, e)
+}
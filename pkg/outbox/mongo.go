@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// MongoField is the array field of a document that holds its events.
+	MongoField = "outbox"
+	// MongoFlag is set on documents with pending events, so that polling
+	// reads them from an index instead of scanning the collection. Writers
+	// set it whenever they add an event; the store unsets it when the last
+	// pending event is removed or fails.
+	MongoFlag = "hasOutbox"
+)
+
+// MongoStore reads the events embedded in the documents of a collection.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoStore(coll *mongo.Collection) *MongoStore {
+	return &MongoStore{
+		coll: coll,
+	}
+}
+
+func (ms *MongoStore) Pending(ctx context.Context, limit int) ([]*Event, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{MongoFlag: true}}},
+		{{Key: "$unwind", Value: "$" + MongoField}},
+		{{Key: "$replaceWith", Value: "$" + MongoField}},
+		{{Key: "$match", Value: bson.M{
+			"failed":      bson.M{"$exists": false},
+			"nextAttempt": bson.M{"$lte": time.Now().UTC()},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "nextAttempt", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cur, err := ms.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0, limit)
+	err = cur.All(ctx, &events)
+
+	return events, err
+}
+
+func (ms *MongoStore) Remove(ctx context.Context, eventID string) error {
+	events := bson.M{"$filter": bson.M{
+		"input": "$" + MongoField,
+		"cond":  bson.M{"$ne": bson.A{"$$this.id", eventID}},
+	}}
+
+	return ms.update(ctx, eventID, events)
+}
+
+func (ms *MongoStore) Retry(ctx context.Context, e *Event) error {
+	filter := bson.M{MongoField + ".id": e.ID}
+	update := bson.M{"$set": bson.M{
+		MongoField + ".$.attempts":    e.Attempts,
+		MongoField + ".$.nextAttempt": e.NextAttempt,
+		MongoField + ".$.error":       e.Error,
+	}}
+
+	_, err := ms.coll.UpdateOne(ctx, filter, update)
+
+	return err
+}
+
+func (ms *MongoStore) Fail(ctx context.Context, e *Event) error {
+	events := bson.M{"$map": bson.M{
+		"input": "$" + MongoField,
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$$this.id", e.ID}},
+			bson.M{"$mergeObjects": bson.A{"$$this", bson.M{
+				"attempts": e.Attempts,
+				"error":    e.Error,
+				"failed":   "$$NOW",
+			}}},
+			"$$this",
+		}},
+	}}
+
+	return ms.update(ctx, e.ID, events)
+}
+
+// update replaces the events of the document holding the event with the
+// given ID and sets MongoFlag to whether any of them is still pending.
+func (ms *MongoStore) update(ctx context.Context, eventID string, events bson.M) error {
+	pending := bson.M{"$anyElementTrue": bson.A{bson.M{"$map": bson.M{
+		"input": "$" + MongoField,
+		"in":    bson.M{"$not": bson.A{"$$this.failed"}},
+	}}}}
+
+	filter := bson.M{MongoField + ".id": eventID}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{MongoField: events}}},
+		{{Key: "$set", Value: bson.M{MongoFlag: bson.M{"$cond": bson.A{pending, true, "$$REMOVE"}}}}},
+	}
+
+	_, err := ms.coll.UpdateOne(ctx, filter, update)
+
+	return err
+}
@@ -0,0 +1,119 @@
+// Package outbox records domain events together with the data changes that
+// cause them and delivers the events to in-process subscribers.
+//
+// Events are written in the same operation as the change: posts keep their
+// pending events in the outbox array of the post document, which Mongo
+// updates atomically with the rest of the document, and users get a row in
+// the Postgres outbox table inside the transaction that creates them. The
+// Dispatcher then polls the stores and removes an event only after every
+// subscriber has handled it, so subscribers may see an event more than once
+// and must be idempotent. A failed event records its attempts and when it is
+// due again in its store; after the last attempt it is marked failed and kept
+// for inspection instead of being retried.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PostCreated    = "PostCreated"
+	CommentCreated = "CommentCreated"
+	VoteCast       = "VoteCast"
+	UserRegistered = "UserRegistered"
+)
+
+// Event is a domain event. Only the fields relevant to its type are set.
+type Event struct {
+	ID        string    `json:"id" bson:"id"`
+	Type      string    `json:"type" bson:"type"`
+	PostID    string    `json:"postId,omitempty" bson:"postId,omitempty"`
+	CommentID string    `json:"commentId,omitempty" bson:"commentId,omitempty"`
+	UserID    string    `json:"userId,omitempty" bson:"userId,omitempty"`
+	Username  string    `json:"username,omitempty" bson:"username,omitempty"`
+	Vote      int       `json:"vote,omitempty" bson:"vote,omitempty"`
+	OldScore  int       `json:"oldScore" bson:"oldScore"`
+	Score     int       `json:"score" bson:"score"`
+	Created   time.Time `json:"created" bson:"created"`
+	// Attempts counts the failed deliveries of the event, NextAttempt is when
+	// it is due, and Error is why the last delivery failed. Failed is set
+	// once the Dispatcher has given up on the event.
+	Attempts    int        `json:"attempts,omitempty" bson:"attempts,omitempty"`
+	NextAttempt time.Time  `json:"nextAttempt" bson:"nextAttempt"`
+	Error       string     `json:"error,omitempty" bson:"error,omitempty"`
+	Failed      *time.Time `json:"failed,omitempty" bson:"failed,omitempty"`
+}
+
+// Store is a place events are recorded in.
+type Store interface {
+	// Pending returns up to limit events that are due and have not failed,
+	// the longest due first.
+	Pending(ctx context.Context, limit int) ([]*Event, error)
+	// Remove deletes a delivered event.
+	Remove(ctx context.Context, eventID string) error
+	// Retry records the Attempts, NextAttempt and Error of an event whose
+	// delivery failed.
+	Retry(ctx context.Context, e *Event) error
+	// Fail records the Attempts and Error of an event and marks it failed,
+	// so it is no longer pending.
+	Fail(ctx context.Context, e *Event) error
+}
+
+func NewEvent(eventType string) *Event {
+	now := time.Now().UTC()
+
+	return &Event{
+		ID:          uuid.NewString(),
+		Type:        eventType,
+		Created:     now,
+		NextAttempt: now,
+	}
+}
+
+// NewPostCreated is recorded when a user creates a post.
+func NewPostCreated(postID, userID, username string) *Event {
+	e := NewEvent(PostCreated)
+	e.PostID = postID
+	e.UserID = userID
+	e.Username = username
+
+	return e
+}
+
+// NewCommentCreated is recorded when a user comments on a post.
+func NewCommentCreated(postID, commentID, userID, username string) *Event {
+	e := NewEvent(CommentCreated)
+	e.PostID = postID
+	e.CommentID = commentID
+	e.UserID = userID
+	e.Username = username
+
+	return e
+}
+
+// NewVoteCast is recorded when a user votes for a post or, if commentID is
+// set, for a comment. OldScore and Score are the scores of the voted item
+// before and after the vote.
+func NewVoteCast(postID, commentID, username string, vote, oldScore, score int) *Event {
+	e := NewEvent(VoteCast)
+	e.PostID = postID
+	e.CommentID = commentID
+	e.Username = username
+	e.Vote = vote
+	e.OldScore = oldScore
+	e.Score = score
+
+	return e
+}
+
+// NewUserRegistered is recorded when a user signs up.
+func NewUserRegistered(userID, username string) *Event {
+	e := NewEvent(UserRegistered)
+	e.UserID = userID
+	e.Username = username
+
+	return e
+}
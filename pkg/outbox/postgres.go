@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PGStore reads the events of the Postgres outbox table.
+type PGStore struct {
+	pgPool *pgxpool.Pool
+}
+
+func NewPGStore(pgPool *pgxpool.Pool) *PGStore {
+	return &PGStore{
+		pgPool: pgPool,
+	}
+}
+
+// Insert records the event within tx, so it is kept only if tx commits.
+func Insert(ctx context.Context, tx pgx.Tx, e *Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO outbox (id, type, payload, created, next_attempt) VALUES ($1, $2, $3, $4, $5)",
+		e.ID,
+		e.Type,
+		payload,
+		e.Created,
+		e.NextAttempt,
+	)
+
+	return err
+}
+
+func (ps *PGStore) Pending(ctx context.Context, limit int) ([]*Event, error) {
+	rows, err := ps.pgPool.Query(
+		ctx,
+		`SELECT payload, attempts, next_attempt, last_error
+		FROM outbox
+		WHERE failed_at IS NULL AND next_attempt <= NOW()
+		ORDER BY next_attempt
+		LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*Event, 0, limit)
+	for rows.Next() {
+		var (
+			payload     []byte
+			attempts    int
+			nextAttempt time.Time
+			lastError   string
+		)
+		err = rows.Scan(&payload, &attempts, &nextAttempt, &lastError)
+		if err != nil {
+			return nil, err
+		}
+
+		e := &Event{}
+		err = json.Unmarshal(payload, e)
+		if err != nil {
+			return nil, err
+		}
+		e.Attempts, e.NextAttempt, e.Error = attempts, nextAttempt, lastError
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (ps *PGStore) Remove(ctx context.Context, eventID string) error {
+	_, err := ps.pgPool.Exec(ctx, "DELETE FROM outbox WHERE id = $1", eventID)
+
+	return err
+}
+
+func (ps *PGStore) Retry(ctx context.Context, e *Event) error {
+	_, err := ps.pgPool.Exec(
+		ctx,
+		"UPDATE outbox SET attempts = $2, next_attempt = $3, last_error = $4 WHERE id = $1",
+		e.ID,
+		e.Attempts,
+		e.NextAttempt,
+		e.Error,
+	)
+
+	return err
+}
+
+func (ps *PGStore) Fail(ctx context.Context, e *Event) error {
+	_, err := ps.pgPool.Exec(
+		ctx,
+		"UPDATE outbox SET attempts = $2, last_error = $3, failed_at = NOW() WHERE id = $1",
+		e.ID,
+		e.Attempts,
+		e.Error,
+	)
+
+	return err
+}
@@ -5,10 +5,16 @@ import (
 	"log"
 	"time"
 
+	"github.com/teatah/rclone/pkg/outbox"
 	"github.com/teatah/rclone/pkg/user"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ViewWindow is the period during which repeated views of a post by the same
+// viewer are counted once. It is also the TTL of the views collection index,
+// so changing it needs a new Mongo migration.
+const ViewWindow = time.Hour * 24
+
 const (
 	Downnvote = iota - 1
 	Unvote
@@ -38,6 +44,15 @@ type Post struct {
 	Created          time.Time          `json:"created" bson:"created"`
 	UpvotePercentage int                `json:"upvotePercentage" bson:"upvotePercentage"`
 	ID               string             `json:"id" bson:"id"`
+	Outbox           []*outbox.Event    `json:"-" bson:"outbox,omitempty"`
+	HasOutbox        bool               `json:"-" bson:"hasOutbox,omitempty"`
+}
+
+// DeletedPost is a post moved to the trash by DeletePost. RestorePost brings
+// it back.
+type DeletedPost struct {
+	Post    `bson:",inline"`
+	Deleted time.Time `json:"deleted" bson:"deleted"`
 }
 
 type CommentsMap map[string]*Comment
@@ -74,27 +89,106 @@ type Vote struct {
 }
 
 type Comment struct {
-	BSONID  primitive.ObjectID `json:"-" bson:"_id"`
-	Created time.Time          `json:"created" bson:"created"`
-	Author  *Author            `json:"author" bson:"author"`
-	Body    string             `json:"body" bson:"body"`
-	ID      string             `json:"id" bson:"id"`
+	BSONID   primitive.ObjectID `json:"-" bson:"_id"`
+	Created  time.Time          `json:"created" bson:"created"`
+	Author   *Author            `json:"author" bson:"author"`
+	Body     string             `json:"body" bson:"body"`
+	ParentID string             `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	Score    int                `json:"score" bson:"score"`
+	Votes    []*Vote            `json:"votes" bson:"votes"`
+	ID       string             `json:"id" bson:"id"`
+}
+
+// View is a single counted view of a post. Its ID combines the post ID and
+// the viewer, so a viewer is counted at most once until the view expires.
+type View struct {
+	ID      string    `bson:"_id"`
+	Created time.Time `bson:"created"`
 }
 
+// UserComment is a comment listed together with the post it belongs to.
+type UserComment struct {
+	Comment   `bson:",inline"`
+	PostID    string `json:"postId" bson:"postId"`
+	PostTitle string `json:"postTitle" bson:"postTitle"`
+}
+
+const (
+	SortNew = "new"
+	SortOld = "old"
+	SortTop = "top"
+)
+
 type CommentRequest struct {
-	Comment string `json:"comment"`
+	Comment  string `json:"comment"`
+	ParentID string `json:"parentId,omitempty"`
 }
 
 type PostRepo interface {
 	AllPosts(ctx context.Context) (*[]Post, error)
 	CreatePost(ctx context.Context, user *user.User, pr *PostRequest) (*Post, error)
-	DeletePost(ctx context.Context, post string) error
+	DeletePost(ctx context.Context, post string) (*Post, error)
 	Post(ctx context.Context, postID string) (*Post, error)
+	ViewPost(ctx context.Context, postID string, viewer string) (*Post, error)
 	PostsByCategory(ctx context.Context, category string) (*[]Post, error)
-	CreateComment(ctx context.Context, postID string, text string, user *user.User) (*Post, error)
-	DeleteComment(ctx context.Context, postID string, commentID string, username string) (*Post, error)
+	CreateComment(ctx context.Context, postID string, cr *CommentRequest, user *user.User) (*Post, error)
+	DeleteComment(ctx context.Context, postID string, commentID string, username string) (*Post, *Comment, error)
 	Vote(ctx context.Context, postID string, username string, voteVal int) (*Post, error)
+	VoteComment(ctx context.Context, postID string, commentID string, username string, voteVal int) (*Post, error)
 	PostsByUser(ctx context.Context, username string) (*[]Post, error)
+	CommentsByUser(ctx context.Context, username string, sort string, offset int, limit int) (*[]UserComment, error)
+	KarmaByAuthor(ctx context.Context) (map[string]user.Karma, error)
+	CountsByAuthor(ctx context.Context, userID string) (posts int, comments int, err error)
+	RestorePost(ctx context.Context, postID string) (*Post, error)
+	DeletedPosts(ctx context.Context, offset int, limit int) (*[]DeletedPost, error)
+	CountPosts(ctx context.Context) (posts int, comments int, err error)
+}
+
+const (
+	EventPostCreated    = "post_created"
+	EventPostDeleted    = "post_deleted"
+	EventPostVoted      = "post_voted"
+	EventCommentCreated = "comment_created"
+	EventCommentDeleted = "comment_deleted"
+	EventCommentVoted   = "comment_voted"
+)
+
+// Event describes a change made to a post. Post holds the post state after the
+// change, or before it for deletions.
+type Event struct {
+	Type      string `json:"type"`
+	Post      *Post  `json:"post"`
+	CommentID string `json:"commentId,omitempty"`
+}
+
+// Publisher receives events about the changes made by PostDBRepo.
+type Publisher interface {
+	Publish(e *Event)
+}
+
+// Publishers sends every event to each of its publishers.
+type Publishers []Publisher
+
+func (ps Publishers) Publish(e *Event) {
+	for _, p := range ps {
+		p.Publish(e)
+	}
+}
+
+// KarmaRepo receives karma changes caused by votes on posts and comments.
+type KarmaRepo interface {
+	AddKarma(ctx context.Context, userID string, delta user.Karma) error
+}
+
+// ReconcileKarma recomputes the karma of all users from the current scores
+// of their posts and comments.
+func ReconcileKarma(ctx context.Context, pr PostRepo, ur user.UserRepo) error {
+	karma, err := pr.KarmaByAuthor(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ur.ResetKarma(ctx, karma)
 }
 
 func NewPost(postRequest *PostRequest, user *user.User) *Post {
@@ -148,6 +242,33 @@ func (p *Post) CalcScoreAndUpvotePercentage() {
 	}
 }
 
+func NewView(postID string, viewer string) *View {
+	return &View{
+		ID:      postID + ":" + viewer,
+		Created: time.Now().UTC(),
+	}
+}
+
+// LastComment returns the most recently added comment.
+func (p *Post) LastComment() *Comment {
+	if len(p.Comments) == 0 {
+		return nil
+	}
+
+	return p.Comments[len(p.Comments)-1]
+}
+
+// outboxEvent returns the pending event with the given ID, or nil.
+func (p *Post) outboxEvent(eventID string) *outbox.Event {
+	for _, e := range p.Outbox {
+		if e.ID == eventID {
+			return e
+		}
+	}
+
+	return nil
+}
+
 func (p *Post) IncreaseViews() {
 	p.Views++
 }
@@ -6,20 +6,51 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/teatah/rclone/pkg/outbox"
 	"github.com/teatah/rclone/pkg/user"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrPostNotFound is wrapped by the errors returned for missing posts.
+	ErrPostNotFound = errors.New("not found")
+	// ErrCommentNotFound is wrapped by the errors returned for missing
+	// comments.
+	ErrCommentNotFound = errors.New("not found")
+	// ErrInvalidID is wrapped by the errors returned for malformed post and
+	// comment IDs.
+	ErrInvalidID = errors.New("invalid id")
 )
 
 type PostDBRepo struct {
 	postsColl *mongo.Collection
+	viewsColl *mongo.Collection
+	trashColl *mongo.Collection
+	karmaRepo KarmaRepo
+	publisher Publisher
+	// Logger reports karma updates that failed after the change to the post
+	// was saved. The karma job corrects them later.
+	Logger *zap.SugaredLogger
 }
 
-func NewPostDBRepo(postsCollection *mongo.Collection) *PostDBRepo {
+func NewPostDBRepo(
+	postsCollection *mongo.Collection,
+	viewsCollection *mongo.Collection,
+	trashCollection *mongo.Collection,
+	karmaRepo KarmaRepo,
+	publisher Publisher,
+) *PostDBRepo {
 	return &PostDBRepo{
 		postsColl: postsCollection,
+		viewsColl: viewsCollection,
+		trashColl: trashCollection,
+		karmaRepo: karmaRepo,
+		publisher: publisher,
+		Logger:    zap.NewNop().Sugar(),
 	}
 }
 
@@ -36,53 +67,170 @@ func (pr *PostDBRepo) AllPosts(ctx context.Context) (*[]Post, error) {
 	return &allPosts, err
 }
 
-func (pr *PostDBRepo) CreatePost(ctx context.Context, user *user.User, postRequest *PostRequest) (*Post, error) {
-	newPost := NewPost(postRequest, user)
+func (pr *PostDBRepo) CreatePost(ctx context.Context, author *user.User, postRequest *PostRequest) (*Post, error) {
+	newPost := NewPost(postRequest, author)
+	newPost.Outbox = []*outbox.Event{outbox.NewPostCreated(newPost.ID, author.ID, author.Username)}
+	newPost.HasOutbox = true
 
 	_, err := pr.postsColl.InsertOne(ctx, newPost)
 	if err != nil {
 		return nil, err
 	}
 
+	pr.publish(&Event{Type: EventPostCreated, Post: newPost})
+
+	pr.addKarma(ctx, newPost.Author.ID, user.Karma{Post: newPost.Score})
+
 	return newPost, nil
 }
 
-func (pr *PostDBRepo) DeletePost(ctx context.Context, postID string) error {
-	bsonID, err := primitive.ObjectIDFromHex(postID)
+// DeletePost moves the post to the trash and returns it.
+func (pr *PostDBRepo) DeletePost(ctx context.Context, postID string) (*Post, error) {
+	bsonID, err := parseID(postID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	res, err := pr.postsColl.DeleteOne(ctx, bson.M{"_id": bsonID})
-	if err == nil && res.DeletedCount == 0 {
-		err = fmt.Errorf("failed ro delete post %s: post not found", postID)
+	deletedPost := &Post{}
+
+	err = pr.postsColl.FindOneAndDelete(ctx, bson.M{"_id": bsonID}).Decode(deletedPost)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed ro delete post %s: post %w", postID, ErrPostNotFound)
+		}
+
+		return nil, err
 	}
 
-	return err
+	// Pending events go to the trash with the post, which is not polled.
+	// They are dispatched if the post is restored; until then the subscribers
+	// would skip them anyway.
+	trashed := &DeletedPost{Post: *deletedPost, Deleted: time.Now().UTC()}
+
+	opt := options.Replace().SetUpsert(true)
+	_, err = pr.trashColl.ReplaceOne(ctx, bson.M{"_id": bsonID}, trashed, opt)
+	if err != nil {
+		_, restoreErr := pr.postsColl.InsertOne(ctx, deletedPost)
+
+		return nil, errors.Join(err, restoreErr)
+	}
+
+	pr.publish(&Event{Type: EventPostDeleted, Post: deletedPost})
+
+	pr.addPostKarma(ctx, deletedPost, -1)
+
+	return deletedPost, nil
+}
+
+// RestorePost moves a deleted post back from the trash.
+func (pr *PostDBRepo) RestorePost(ctx context.Context, postID string) (*Post, error) {
+	bsonID, err := parseID(postID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"_id": bsonID}
+
+	trashed := &DeletedPost{}
+
+	err = pr.trashColl.FindOne(ctx, filter).Decode(trashed)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("deleted post with id %s %w", postID, ErrPostNotFound)
+		}
+
+		return nil, err
+	}
+
+	restoredPost := &trashed.Post
+
+	_, err = pr.postsColl.InsertOne(ctx, restoredPost)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = pr.trashColl.DeleteOne(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pr.addPostKarma(ctx, restoredPost, 1)
+
+	return restoredPost, nil
+}
+
+// DeletedPosts lists the posts in the trash, most recently deleted first.
+func (pr *PostDBRepo) DeletedPosts(ctx context.Context, offset int, limit int) (*[]DeletedPost, error) {
+	opt := options.Find().
+		SetSort(bson.D{{Key: "deleted", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	cur, err := pr.trashColl.Find(ctx, bson.M{}, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	deletedPosts := make([]DeletedPost, 0, limit)
+	err = cur.All(ctx, &deletedPosts)
+
+	return &deletedPosts, err
 }
 
 func (pr *PostDBRepo) Post(ctx context.Context, postID string) (*Post, error) {
-	_id, err := primitive.ObjectIDFromHex(postID)
+	_id, err := parseID(postID)
 	if err != nil {
 		return nil, err
 	}
 
 	post := &Post{}
 
-	filter := bson.M{"_id": _id}
+	err = pr.postsColl.FindOne(ctx, bson.M{"_id": _id}).Decode(post)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("post with id %s %w", postID, ErrPostNotFound)
+		}
+
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// ViewPost returns the post and counts a view of it unless the viewer has
+// already viewed it within ViewWindow. Views of posts that do not exist are
+// not recorded.
+func (pr *PostDBRepo) ViewPost(ctx context.Context, postID string, viewer string) (*Post, error) {
+	post, err := pr.Post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = pr.viewsColl.InsertOne(ctx, NewView(postID, viewer))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return post, nil
+		}
+
+		return nil, err
+	}
+
+	viewedPost := &Post{}
+
+	filter := bson.M{"_id": post.BSONID}
 	update := bson.M{"$inc": bson.M{"views": 1}}
 
 	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	err = pr.postsColl.FindOneAndUpdate(ctx, filter, update, opt).Decode(post)
+	err = pr.postsColl.FindOneAndUpdate(ctx, filter, update, opt).Decode(viewedPost)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, fmt.Errorf("post with id %s not found", postID)
+			return nil, fmt.Errorf("post with id %s %w", postID, ErrPostNotFound)
 		}
 
 		return nil, err
 	}
 
-	return post, nil
+	return viewedPost, nil
 }
 
 func (pr *PostDBRepo) PostsByCategory(ctx context.Context, category string) (*[]Post, error) {
@@ -92,7 +240,7 @@ func (pr *PostDBRepo) PostsByCategory(ctx context.Context, category string) (*[]
 func (pr *PostDBRepo) CreateComment(
 	ctx context.Context,
 	postID string,
-	text string,
+	commentRequest *CommentRequest,
 	user *user.User,
 ) (*Post, error) {
 	commentBSONID := primitive.NewObjectID()
@@ -104,96 +252,273 @@ func (pr *PostDBRepo) CreateComment(
 			Username: user.Username,
 			ID:       user.ID,
 		},
-		Body: text,
+		Body:     commentRequest.Comment,
+		ParentID: commentRequest.ParentID,
+		Votes:    make([]*Vote, 0),
 	}
 
 	updatedPost := &Post{}
 
-	bsonID, err := primitive.ObjectIDFromHex(postID)
+	bsonID, err := parseID(postID)
 	if err != nil {
 		return nil, err
 	}
 
 	filter := bson.M{"_id": bsonID}
-	update := bson.M{"$push": bson.M{"comments": comment}}
+	if len(comment.ParentID) != 0 {
+		filter["comments.id"] = comment.ParentID
+	}
+	update := bson.M{
+		"$push": bson.M{
+			"comments":        comment,
+			outbox.MongoField: outbox.NewCommentCreated(postID, comment.ID, user.ID, user.Username),
+		},
+		"$set": bson.M{outbox.MongoFlag: true},
+	}
 
 	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	err = pr.postsColl.FindOneAndUpdate(ctx, filter, update, opt).Decode(updatedPost)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if len(comment.ParentID) != 0 {
+			return nil, fmt.Errorf("post with id %s or comment with id %s %w", postID, comment.ParentID, ErrCommentNotFound)
+		}
+
+		return nil, fmt.Errorf("post with id %s %w", postID, ErrPostNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return updatedPost, err
+	pr.publish(&Event{Type: EventCommentCreated, Post: updatedPost, CommentID: comment.ID})
+
+	return updatedPost, nil
 }
 
-func (pr *PostDBRepo) DeleteComment(ctx context.Context, postID string, commentID string, username string) (*Post, error) {
-	commentBSONID, err := primitive.ObjectIDFromHex(commentID)
+// DeleteComment removes the comment and returns the updated post together
+// with the deleted comment.
+func (pr *PostDBRepo) DeleteComment(
+	ctx context.Context,
+	postID string,
+	commentID string,
+	username string,
+) (*Post, *Comment, error) {
+	commentBSONID, err := parseID(commentID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	updatedPost := &Post{}
+	oldPost := &Post{}
 
-	bsonID, err := primitive.ObjectIDFromHex(postID)
+	bsonID, err := parseID(postID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	filter := bson.M{"_id": bsonID}
 	update := bson.M{"$pull": bson.M{"comments": bson.M{"_id": commentBSONID}}}
 
-	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	err = pr.postsColl.FindOneAndUpdate(ctx, filter, update, opt).Decode(updatedPost)
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err = pr.postsColl.FindOneAndUpdate(ctx, filter, update, opt).Decode(oldPost)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var deletedComment *Comment
+	updatedPost := oldPost
+	updatedComments := make([]*Comment, 0, len(oldPost.Comments))
+	for _, comment := range oldPost.Comments {
+		if comment.BSONID != commentBSONID {
+			updatedComments = append(updatedComments, comment)
+			continue
+		}
+		deletedComment = comment
+
+		pr.addKarma(ctx, comment.Author.ID, user.Karma{Comment: -comment.Score})
+	}
+	updatedPost.Comments = updatedComments
+
+	if deletedComment == nil {
+		return nil, nil, fmt.Errorf("comment with id %s %w", commentID, ErrCommentNotFound)
+	}
+
+	pr.publish(&Event{Type: EventCommentDeleted, Post: updatedPost, CommentID: commentID})
 
-	return updatedPost, err
+	return updatedPost, deletedComment, nil
 }
 
+// Vote sets the vote of the user for the post in a single pipeline update, so
+// concurrent votes do not overwrite each other. The scores in the recorded
+// VoteCast event and the karma change come from the update itself.
 func (pr *PostDBRepo) Vote(ctx context.Context, postID string, username string, voteVal int) (*Post, error) {
-	bsonID, err := primitive.ObjectIDFromHex(postID)
+	bsonID, err := parseID(postID)
 	if err != nil {
 		return nil, err
 	}
 
-	filter := bson.M{"_id": bsonID}
+	event := outbox.NewVoteCast(postID, "", username, voteVal, 0, 0)
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			voteOldScoreField: "$score",
+			"votes":           setVoteExpr("$votes", username, voteVal),
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"score": bson.M{"$sum": "$votes.vote"},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"upvotePercentage": upvotePercentageExpr("$votes", "$score"),
+			outbox.MongoField:  pushEventExpr(event, "$"+voteOldScoreField, "$score"),
+			outbox.MongoFlag:   true,
+		}}},
+		{{Key: "$unset", Value: voteOldScoreField}},
+	}
 
-	pullUpdate := bson.M{"$pull": bson.M{"votes": bson.M{"user": username}}}
+	updatedPost := &Post{}
 
-	_, err = pr.postsColl.UpdateOne(ctx, filter, pullUpdate)
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = pr.postsColl.FindOneAndUpdate(ctx, bson.M{"_id": bsonID}, update, opt).Decode(updatedPost)
 	if err != nil {
 		return nil, err
 	}
 
-	updatedPost := &Post{}
+	pr.publish(&Event{Type: EventPostVoted, Post: updatedPost})
+
+	written := updatedPost.outboxEvent(event.ID)
+	if written != nil {
+		pr.addKarma(ctx, updatedPost.Author.ID, user.Karma{Post: written.Score - written.OldScore})
+	}
 
-	err = pr.postsColl.FindOne(ctx, filter).Decode(updatedPost)
+	return updatedPost, nil
+}
+
+// VoteComment sets the vote of the user for the comment in a single pipeline
+// update, like Vote.
+func (pr *PostDBRepo) VoteComment(
+	ctx context.Context,
+	postID string,
+	commentID string,
+	username string,
+	voteVal int,
+) (*Post, error) {
+	commentBSONID, err := parseID(commentID)
 	if err != nil {
 		return nil, err
 	}
 
-	if voteVal != 0 {
-		vote := &Vote{
-			User: username,
-			Vote: voteVal,
-		}
+	bsonID, err := parseID(postID)
+	if err != nil {
+		return nil, err
+	}
 
-		updatedPost.Votes = append(updatedPost.Votes, vote)
+	event := outbox.NewVoteCast(postID, commentID, username, voteVal, 0, 0)
+
+	isComment := bson.M{"$eq": bson.A{"$$c._id", commentBSONID}}
+	commentScore := bson.M{"$arrayElemAt": bson.A{
+		"$comments.score",
+		bson.M{"$indexOfArray": bson.A{"$comments._id", commentBSONID}},
+	}}
+	updateComment := func(fields bson.M) bson.M {
+		return bson.M{"$map": bson.M{
+			"input": "$comments",
+			"as":    "c",
+			"in": bson.M{"$cond": bson.A{
+				isComment,
+				bson.M{"$mergeObjects": bson.A{"$$c", fields}},
+				"$$c",
+			}},
+		}}
 	}
-	updatedPost.CalcScoreAndUpvotePercentage()
 
-	updateStats := bson.M{
-		"$set": bson.M{
-			"score":            updatedPost.Score,
-			"upvotePercentage": updatedPost.UpvotePercentage,
-			"votes":            updatedPost.Votes,
-		},
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			voteOldScoreField: commentScore,
+			"comments":        updateComment(bson.M{"votes": setVoteExpr("$$c.votes", username, voteVal)}),
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"comments": updateComment(bson.M{"score": bson.M{"$sum": "$$c.votes.vote"}}),
+		}}},
+		{{Key: "$set", Value: bson.M{
+			outbox.MongoField: pushEventExpr(event, "$"+voteOldScoreField, commentScore),
+			outbox.MongoFlag:  true,
+		}}},
+		{{Key: "$unset", Value: voteOldScoreField}},
+	}
+
+	filter := bson.M{"_id": bsonID, "comments._id": commentBSONID}
+
+	updatedPost := &Post{}
+
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = pr.postsColl.FindOneAndUpdate(ctx, filter, update, opt).Decode(updatedPost)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		count, countErr := pr.postsColl.CountDocuments(ctx, bson.M{"_id": bsonID})
+		if countErr == nil && count != 0 {
+			return nil, fmt.Errorf("comment with id %s %w", commentID, ErrCommentNotFound)
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = pr.postsColl.UpdateOne(ctx, filter, updateStats)
+	pr.publish(&Event{Type: EventCommentVoted, Post: updatedPost, CommentID: commentID})
 
-	return updatedPost, err
+	written := updatedPost.outboxEvent(event.ID)
+	for _, c := range updatedPost.Comments {
+		if c.BSONID == commentBSONID && written != nil {
+			pr.addKarma(ctx, c.Author.ID, user.Karma{Comment: written.Score - written.OldScore})
+		}
+	}
+
+	return updatedPost, nil
 }
 
 func (pr *PostDBRepo) PostsByUser(ctx context.Context, username string) (*[]Post, error) {
 	return pr.listByKeyValue(ctx, "author.username", username)
 }
 
+func (pr *PostDBRepo) CommentsByUser(
+	ctx context.Context,
+	username string,
+	sort string,
+	offset int,
+	limit int,
+) (*[]UserComment, error) {
+	var sortStage bson.D
+	switch sort {
+	case SortOld:
+		sortStage = bson.D{{Key: "created", Value: 1}}
+	case SortTop:
+		sortStage = bson.D{{Key: "score", Value: -1}, {Key: "created", Value: -1}}
+	default:
+		sortStage = bson.D{{Key: "created", Value: -1}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"comments.author.username": username}}},
+		{{Key: "$unwind", Value: "$comments"}},
+		{{Key: "$match", Value: bson.M{"comments.author.username": username}}},
+		{{Key: "$replaceWith", Value: bson.M{
+			"$mergeObjects": bson.A{
+				"$comments",
+				bson.M{"postId": "$id", "postTitle": "$title"},
+			},
+		}}},
+		{{Key: "$sort", Value: sortStage}},
+		{{Key: "$skip", Value: offset}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	userComments := make([]UserComment, 0, limit)
+	cur, err := pr.postsColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.All(ctx, &userComments)
+
+	return &userComments, err
+}
+
 func (pr *PostDBRepo) listByKeyValue(ctx context.Context, key, value string) (*[]Post, error) {
 	var catPosts []Post
 	cur, err := pr.postsColl.Find(ctx, bson.M{key: value})
@@ -205,3 +530,211 @@ func (pr *PostDBRepo) listByKeyValue(ctx context.Context, key, value string) (*[
 
 	return &catPosts, err
 }
+
+// KarmaByAuthor sums the scores of posts and comments by their authors.
+func (pr *PostDBRepo) KarmaByAuthor(ctx context.Context) (map[string]user.Karma, error) {
+	type authorScore struct {
+		AuthorID string `bson:"_id"`
+		Score    int    `bson:"score"`
+	}
+
+	karma := make(map[string]user.Karma)
+
+	postsPipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$author.id", "score": bson.M{"$sum": "$score"}}}},
+	}
+
+	var postScores []authorScore
+	cur, err := pr.postsColl.Aggregate(ctx, postsPipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.All(ctx, &postScores)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ps := range postScores {
+		k := karma[ps.AuthorID]
+		k.Post = ps.Score
+		karma[ps.AuthorID] = k
+	}
+
+	commentsPipeline := mongo.Pipeline{
+		{{Key: "$unwind", Value: "$comments"}},
+		{{Key: "$group", Value: bson.M{"_id": "$comments.author.id", "score": bson.M{"$sum": "$comments.score"}}}},
+	}
+
+	var commentScores []authorScore
+	cur, err = pr.postsColl.Aggregate(ctx, commentsPipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.All(ctx, &commentScores)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cs := range commentScores {
+		k := karma[cs.AuthorID]
+		k.Comment = cs.Score
+		karma[cs.AuthorID] = k
+	}
+
+	return karma, nil
+}
+
+// CountPosts returns the number of posts and comments in the forum.
+func (pr *PostDBRepo) CountPosts(ctx context.Context) (int, int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":      nil,
+			"posts":    bson.M{"$sum": 1},
+			"comments": bson.M{"$sum": bson.M{"$size": bson.M{"$ifNull": bson.A{"$comments", bson.A{}}}}},
+		}}},
+	}
+
+	var counts []struct {
+		Posts    int `bson:"posts"`
+		Comments int `bson:"comments"`
+	}
+	cur, err := pr.postsColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = cur.All(ctx, &counts)
+	if err != nil || len(counts) == 0 {
+		return 0, 0, err
+	}
+
+	return counts[0].Posts, counts[0].Comments, nil
+}
+
+// CountsByAuthor returns the number of posts and comments written by the user.
+func (pr *PostDBRepo) CountsByAuthor(ctx context.Context, userID string) (int, int, error) {
+	posts, err := pr.postsColl.CountDocuments(ctx, bson.M{"author.id": userID})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"comments.author.id": userID}}},
+		{{Key: "$unwind", Value: "$comments"}},
+		{{Key: "$match", Value: bson.M{"comments.author.id": userID}}},
+		{{Key: "$count", Value: "comments"}},
+	}
+
+	var counts []struct {
+		Comments int `bson:"comments"`
+	}
+	cur, err := pr.postsColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = cur.All(ctx, &counts)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var comments int
+	if len(counts) != 0 {
+		comments = counts[0].Comments
+	}
+
+	return int(posts), comments, nil
+}
+
+func (pr *PostDBRepo) publish(e *Event) {
+	if pr.publisher == nil {
+		return
+	}
+
+	pr.publisher.Publish(e)
+}
+
+// addPostKarma adds the scores of the post and its comments, multiplied by
+// sign, to the karma of their authors.
+func (pr *PostDBRepo) addPostKarma(ctx context.Context, p *Post, sign int) {
+	pr.addKarma(ctx, p.Author.ID, user.Karma{Post: sign * p.Score})
+
+	for _, comment := range p.Comments {
+		pr.addKarma(ctx, comment.Author.ID, user.Karma{Comment: sign * comment.Score})
+	}
+}
+
+// addKarma adds delta to the karma of the user. It only logs failures: the
+// change to the post is already saved, and ReconcileKarma fixes the karma.
+func (pr *PostDBRepo) addKarma(ctx context.Context, userID string, delta user.Karma) {
+	if pr.karmaRepo == nil || delta == (user.Karma{}) {
+		return
+	}
+
+	err := pr.karmaRepo.AddKarma(ctx, userID, delta)
+	if err != nil {
+		pr.Logger.Errorf("failed to add karma %+v to user %s: %s", delta, userID, err)
+	}
+}
+
+// voteOldScoreField holds the score before a vote while Vote and VoteComment
+// update a post.
+const voteOldScoreField = "voteOldScore"
+
+// setVoteExpr is the aggregation expression for the votes expression with the
+// vote of the user replaced by voteVal. A zero voteVal removes the vote.
+func setVoteExpr(votes string, username string, voteVal int) bson.M {
+	added := bson.A{}
+	if voteVal != 0 {
+		added = append(added, bson.M{"$literal": &Vote{User: username, Vote: voteVal}})
+	}
+
+	return bson.M{"$concatArrays": bson.A{
+		bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{votes, bson.A{}}},
+			"cond":  bson.M{"$ne": bson.A{"$$this.user", username}},
+		}},
+		added,
+	}}
+}
+
+// upvotePercentageExpr computes UpvotePercentage the way
+// CalcScoreAndUpvotePercentage does.
+func upvotePercentageExpr(votes string, score string) bson.M {
+	upvotes := bson.M{"$size": bson.M{"$filter": bson.M{
+		"input": votes,
+		"cond":  bson.M{"$eq": bson.A{"$$this.vote", Upvote}},
+	}}}
+
+	return bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{score, 0}},
+		0,
+		bson.M{"$toInt": bson.M{"$trunc": bson.M{"$divide": bson.A{
+			bson.M{"$multiply": bson.A{upvotes, 100}},
+			bson.M{"$size": votes},
+		}}}},
+	}}
+}
+
+// pushEventExpr appends the VoteCast event to the outbox with the scores
+// given by the oldScore and score expressions.
+func pushEventExpr(e *outbox.Event, oldScore any, score any) bson.M {
+	return bson.M{"$concatArrays": bson.A{
+		bson.M{"$ifNull": bson.A{"$" + outbox.MongoField, bson.A{}}},
+		bson.A{bson.M{"$mergeObjects": bson.A{
+			bson.M{"$literal": e},
+			bson.M{"oldScore": oldScore, "score": score},
+		}}},
+	}}
+}
+
+func parseID(id string) (primitive.ObjectID, error) {
+	bsonID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w %q", ErrInvalidID, id)
+	}
+
+	return bsonID, nil
+}
@@ -37,7 +37,8 @@ type ResponseErrors struct {
 }
 
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
 }
 
 type Message struct {
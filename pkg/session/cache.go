@@ -0,0 +1,268 @@
+package session
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CachedStore is a read-through cache of the most recently used sessions of
+// another store. A session is cached for at most its TTL and never past its
+// expiry. Deletions go through the cache, so sessions ended by this process
+// are rejected at once; sessions deleted from the underlying store by another
+// process, such as rcadmin, are rejected once their entry expires.
+type CachedStore struct {
+	store Store
+	size  int
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// generation changes on every deletion, so a lookup that raced with one
+	// does not cache what it read.
+	generation uint64
+}
+
+type cacheEntry struct {
+	sess    *Session
+	expires time.Time
+}
+
+// NewCachedStore caches up to size sessions of store for ttl each.
+func NewCachedStore(store Store, size int, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		store:   store,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		lru:     list.New(),
+	}
+}
+
+func (cs *CachedStore) Add(ctx context.Context, sess *Session) error {
+	err := cs.store.Add(ctx, sess)
+	if err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.put(sess)
+	return nil
+}
+
+func (cs *CachedStore) Get(ctx context.Context, id string) (*Session, error) {
+	cs.mu.Lock()
+	el, ok := cs.entries[id]
+	if ok {
+		entry := el.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			cs.lru.MoveToFront(el)
+			cs.mu.Unlock()
+			return stored(entry.sess), nil
+		}
+		cs.remove(el)
+	}
+	generation := cs.generation
+	cs.mu.Unlock()
+
+	sess, err := cs.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.generation == generation {
+		cs.put(sess)
+	}
+
+	return sess, nil
+}
+
+func (cs *CachedStore) Delete(ctx context.Context, id string) error {
+	err := cs.store.Delete(ctx, id)
+	cs.forget(func(sess *Session) bool {
+		return sess.ID == id
+	})
+	return err
+}
+
+func (cs *CachedStore) DeleteFamily(ctx context.Context, familyID string) error {
+	err := cs.store.DeleteFamily(ctx, familyID)
+	cs.forget(func(sess *Session) bool {
+		return sess.FamilyID == familyID
+	})
+	return err
+}
+
+func (cs *CachedStore) DeleteUser(ctx context.Context, userID string, keep *Session) (int64, error) {
+	deleted, err := cs.store.DeleteUser(ctx, userID, keep)
+	cs.forget(func(sess *Session) bool {
+		return sess.UserID == userID && !keeps(keep, sess)
+	})
+	return deleted, err
+}
+
+func (cs *CachedStore) DeleteExpired(ctx context.Context) error {
+	err := cs.store.DeleteExpired(ctx)
+	now := time.Now().Unix()
+	cs.forget(func(sess *Session) bool {
+		return sess.ExpiresAt < now
+	})
+	return err
+}
+
+func (cs *CachedStore) Count(ctx context.Context) (int, error) {
+	return cs.store.Count(ctx)
+}
+
+// WithTx returns a view of the store writing through the underlying store's
+// view for tx. Sessions added through it are cached once tx commits. Deleted
+// sessions are forgotten at once and again when tx ends, so a lookup that
+// read them before the deletion committed does not keep them cached.
+func (cs *CachedStore) WithTx(tx pgx.Tx) (Store, func(committed bool)) {
+	store, done := cs.store.WithTx(tx)
+	ct := &cachedTx{cs: cs, store: store}
+
+	return ct, func(committed bool) {
+		done(committed)
+		ct.done(committed)
+	}
+}
+
+// cachedTx is a CachedStore view whose writes belong to a transaction.
+type cachedTx struct {
+	cs        *CachedStore
+	store     Store
+	added     []*Session
+	forgotten []func(*Session) bool
+}
+
+func (ct *cachedTx) Add(ctx context.Context, sess *Session) error {
+	err := ct.store.Add(ctx, sess)
+	if err != nil {
+		return err
+	}
+
+	ct.added = append(ct.added, stored(sess))
+	return nil
+}
+
+func (ct *cachedTx) Get(ctx context.Context, id string) (*Session, error) {
+	return ct.store.Get(ctx, id)
+}
+
+func (ct *cachedTx) Delete(ctx context.Context, id string) error {
+	err := ct.store.Delete(ctx, id)
+	ct.forget(func(sess *Session) bool {
+		return sess.ID == id
+	})
+	return err
+}
+
+func (ct *cachedTx) DeleteFamily(ctx context.Context, familyID string) error {
+	err := ct.store.DeleteFamily(ctx, familyID)
+	ct.forget(func(sess *Session) bool {
+		return sess.FamilyID == familyID
+	})
+	return err
+}
+
+func (ct *cachedTx) DeleteUser(ctx context.Context, userID string, keep *Session) (int64, error) {
+	deleted, err := ct.store.DeleteUser(ctx, userID, keep)
+	ct.forget(func(sess *Session) bool {
+		return sess.UserID == userID && !keeps(keep, sess)
+	})
+	return deleted, err
+}
+
+func (ct *cachedTx) DeleteExpired(ctx context.Context) error {
+	err := ct.store.DeleteExpired(ctx)
+	now := time.Now().Unix()
+	ct.forget(func(sess *Session) bool {
+		return sess.ExpiresAt < now
+	})
+	return err
+}
+
+func (ct *cachedTx) Count(ctx context.Context) (int, error) {
+	return ct.store.Count(ctx)
+}
+
+func (ct *cachedTx) WithTx(_ pgx.Tx) (Store, func(committed bool)) {
+	return ct, func(bool) {}
+}
+
+// forget drops the matching sessions from the cache now and remembers the
+// predicate for when the transaction ends.
+func (ct *cachedTx) forget(match func(*Session) bool) {
+	ct.cs.forget(match)
+	ct.forgotten = append(ct.forgotten, match)
+}
+
+func (ct *cachedTx) done(committed bool) {
+	for _, match := range ct.forgotten {
+		ct.cs.forget(match)
+	}
+
+	if !committed {
+		return
+	}
+
+	ct.cs.mu.Lock()
+	defer ct.cs.mu.Unlock()
+
+	for _, sess := range ct.added {
+		ct.cs.put(sess)
+	}
+}
+
+// put caches sess, evicting the least recently used session when the cache
+// is full. cs.mu must be held.
+func (cs *CachedStore) put(sess *Session) {
+	expires := time.Now().Add(cs.ttl)
+	sessExpires := time.Unix(sess.ExpiresAt, 0)
+	if sessExpires.Before(expires) {
+		expires = sessExpires
+	}
+
+	el, ok := cs.entries[sess.ID]
+	if ok {
+		el.Value = &cacheEntry{sess: stored(sess), expires: expires}
+		cs.lru.MoveToFront(el)
+		return
+	}
+
+	cs.entries[sess.ID] = cs.lru.PushFront(&cacheEntry{sess: stored(sess), expires: expires})
+	if cs.lru.Len() > cs.size {
+		cs.remove(cs.lru.Back())
+	}
+}
+
+// forget drops the cached sessions matching the predicate once they are
+// deleted from the underlying store. It bumps the generation, so lookups that
+// may have read them before the deletion do not cache them again.
+func (cs *CachedStore) forget(match func(*Session) bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.generation++
+	for _, el := range cs.entries {
+		if match(el.Value.(*cacheEntry).sess) {
+			cs.remove(el)
+		}
+	}
+}
+
+// remove drops a cache entry. cs.mu must be held.
+func (cs *CachedStore) remove(el *list.Element) {
+	cs.lru.Remove(el)
+	delete(cs.entries, el.Value.(*cacheEntry).sess.ID)
+}
@@ -0,0 +1,182 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/teatah/rclone/pkg/audit"
+)
+
+// lastSeenResolution limits how often Check records that a session family
+// was used.
+const lastSeenResolution = time.Minute
+
+// ActiveSession is a login of a user as listed to them. Its ID is the family
+// ID shared by the access tokens and refresh tokens descending from the
+// login, so it never reveals a token.
+type ActiveSession struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Current   bool      `json:"current"`
+}
+
+// Sessions lists the active sessions of the user, most recently used first.
+// The one belonging to current is marked.
+func (sm *DBSessionManager) Sessions(ctx context.Context, userID string, current *Session) ([]*ActiveSession, error) {
+	rows, err := sm.pgPool.Query(
+		ctx,
+		`SELECT id, user_agent, ip, created, last_seen
+		FROM session_families
+		WHERE user_id = $1
+		ORDER BY last_seen DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*ActiveSession, error) {
+		s := &ActiveSession{}
+		err := row.Scan(&s.ID, &s.UserAgent, &s.IP, &s.Created, &s.LastSeen)
+		s.Current = s.ID == current.FamilyID
+
+		return s, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Revoke ends the session family of the user with the given ID: its access
+// tokens stop working at once and its refresh tokens can no longer be used.
+func (sm *DBSessionManager) Revoke(ctx context.Context, userID string, familyID string) error {
+	return sm.inTx(ctx, func(tx pgx.Tx, store Store) error {
+		tag, err := tx.Exec(ctx, "DELETE FROM session_families WHERE id = $1 AND user_id = $2", familyID, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrSessionNotFound
+		}
+
+		return revokeFamily(ctx, tx, store, familyID)
+	})
+}
+
+// RevokeOthers ends every session family of the user except the one of keep
+// and returns how many were ended.
+func (sm *DBSessionManager) RevokeOthers(ctx context.Context, userID string, keep *Session) (int64, error) {
+	var revoked int64
+	err := sm.inTx(ctx, func(tx pgx.Tx, store Store) error {
+		tag, err := tx.Exec(
+			ctx,
+			"DELETE FROM session_families WHERE user_id = $1 AND id <> $2",
+			userID,
+			keep.FamilyID,
+		)
+		if err != nil {
+			return err
+		}
+		revoked = tag.RowsAffected()
+
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL`,
+			userID,
+			keep.FamilyID,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = store.DeleteUser(ctx, userID, keep)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, nil
+}
+
+// Logout ends the session family of sess. Sessions started before families
+// existed are ended on their own.
+func (sm *DBSessionManager) Logout(ctx context.Context, sess *Session) error {
+	if len(sess.FamilyID) == 0 {
+		return sm.store.Delete(ctx, sess.ID)
+	}
+
+	return sm.Revoke(ctx, sess.UserID, sess.FamilyID)
+}
+
+// addFamily records a new session family with the client that logged in.
+func addFamily(ctx context.Context, tx pgx.Tx, sess *Session) error {
+	client := audit.ClientFromContext(ctx)
+	_, err := tx.Exec(
+		ctx,
+		"INSERT INTO session_families (id, user_id, user_agent, ip) VALUES ($1, $2, $3, $4)",
+		sess.FamilyID,
+		sess.UserID,
+		client.UserAgent,
+		client.IP,
+	)
+
+	return err
+}
+
+// refreshFamily records the client that refreshed the family's tokens.
+func refreshFamily(ctx context.Context, tx pgx.Tx, familyID string) error {
+	client := audit.ClientFromContext(ctx)
+	_, err := tx.Exec(
+		ctx,
+		"UPDATE session_families SET user_agent = $2, ip = $3, last_seen = NOW() WHERE id = $1",
+		familyID,
+		client.UserAgent,
+		client.IP,
+	)
+
+	return err
+}
+
+// touchFamily updates when the family was last seen, at most once per
+// lastSeenResolution, so most checks do not write to the database.
+func (sm *DBSessionManager) touchFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+
+	sm.seenMu.Lock()
+	seen, ok := sm.seen[familyID]
+	if ok && now.Sub(seen) < lastSeenResolution {
+		sm.seenMu.Unlock()
+		return nil
+	}
+	sm.seen[familyID] = now
+	sm.seenMu.Unlock()
+
+	_, err := sm.pgPool.Exec(
+		ctx,
+		"UPDATE session_families SET last_seen = NOW() WHERE id = $1 AND last_seen < $2",
+		familyID,
+		now.Add(-lastSeenResolution),
+	)
+
+	return err
+}
+
+// forgetSeen drops the families touchFamily has not recorded recently.
+func (sm *DBSessionManager) forgetSeen() {
+	sm.seenMu.Lock()
+	defer sm.seenMu.Unlock()
+
+	for familyID, seen := range sm.seen {
+		if time.Since(seen) >= lastSeenResolution {
+			delete(sm.seen, familyID)
+		}
+	}
+}
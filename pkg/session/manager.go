@@ -2,39 +2,62 @@ package session
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	"fmt"
 	"sync"
+	"time"
 
-	"github.com/jackc/pgx"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/teatah/rclone/pkg/token"
 	"github.com/teatah/rclone/pkg/user"
 )
 
+var ErrSessionNotFound = errors.New("session not found")
+
+// DBSessionManager keeps refresh tokens and session families in Postgres and
+// the sessions behind access tokens in its Store.
 type DBSessionManager struct {
-	mu       *sync.RWMutex
-	sessions map[string]*Session
-	pgPool   *pgxpool.Pool
+	pgPool *pgxpool.Pool
+	store  Store
+	// seen holds when Check last recorded each session family as used.
+	seenMu     *sync.Mutex
+	seen       map[string]time.Time
+	RefreshTTL time.Duration
 }
 
-func NewDBSessionManager(pgPool *pgxpool.Pool) *DBSessionManager {
+func NewDBSessionManager(pgPool *pgxpool.Pool, store Store) *DBSessionManager {
 	return &DBSessionManager{
-		mu:       &sync.RWMutex{},
-		sessions: make(map[string]*Session, 5),
-		pgPool:   pgPool,
+		pgPool:     pgPool,
+		store:      store,
+		seenMu:     &sync.Mutex{},
+		seen:       make(map[string]time.Time),
+		RefreshTTL: DefaultRefreshTTL,
 	}
 }
 
+// Create starts a session for the user together with a new refresh token
+// family.
 func (sm *DBSessionManager) Create(ctx context.Context, user *user.User) (*Session, error) {
 	sess, err := NewSession(user)
-
 	if err != nil {
 		return nil, err
 	}
+	sess.FamilyID = uuid.NewString()
+
+	err = sm.inTx(ctx, func(tx pgx.Tx, store Store) error {
+		err := addFamily(ctx, tx, sess)
+		if err != nil {
+			return err
+		}
 
-	err = sm.addSession(ctx, sess)
+		sess.RefreshToken, err = sm.addRefreshToken(ctx, tx, sess)
+		if err != nil {
+			return err
+		}
+
+		return store.Add(ctx, sess)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -42,71 +65,130 @@ func (sm *DBSessionManager) Create(ctx context.Context, user *user.User) (*Sessi
 	return sess, nil
 }
 
+// Check verifies the token and returns its session. It fails with a
+// *token.ValidationError for a token that does not verify and with
+// ErrSessionNotFound for a valid token whose session has ended.
 func (sm *DBSessionManager) Check(ctx context.Context, tokenString string) (*Session, error) {
-	_, err := token.ParseJwt(tokenString)
+	claims, err := token.ParseJwt(tokenString)
 	if err != nil {
 		return nil, err
 	}
 
-	sess, err := sm.findSession(ctx, tokenString)
+	sess, err := sm.store.Get(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if sess.UserID != claims.User.ID {
+		return nil, ErrSessionNotFound
+	}
+
+	if len(sess.FamilyID) != 0 {
+		err = sm.touchFamily(ctx, sess.FamilyID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return sess, err
+	return sess, nil
 }
 
 func (sm *DBSessionManager) UsernameBySessionID(ctx context.Context, sessID string) (string, error) {
-	var username string
+	sess, err := sm.store.Get(ctx, sessID)
+	if err != nil {
+		return "", err
+	}
 
-	err := sm.pgPool.QueryRow(
-		ctx,
-		`SELECT username
-		FROM users
-		INNER JOIN sessions ON users.id = sessions.user_id
-		WHERE sessions.id = $1`,
-		sessID,
-	).Scan(&username)
-
-	if errors.Is(err, sql.ErrNoRows) {
-		err = fmt.Errorf("session %s not found", sessID)
+	var username string
+	err = sm.pgPool.QueryRow(ctx, "SELECT username FROM users WHERE id = $1", sess.UserID).Scan(&username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = ErrSessionNotFound
 	}
 
 	return username, err
 }
 
-func (sm *DBSessionManager) addSession(ctx context.Context, sess *Session) error {
-	_, err := sm.pgPool.Exec(
-		ctx,
-		"INSERT INTO sessions (id, user_id, expires_at) values ($1, $2, $3)",
-		sess.ID,
-		sess.UserID,
-		sess.ExpiresAt,
-	)
+// RevokeUserSessions deletes all sessions and refresh tokens of the user and
+// returns how many sessions there were.
+func (sm *DBSessionManager) RevokeUserSessions(ctx context.Context, userID string) (int64, error) {
+	var revoked int64
+	err := sm.inTx(ctx, func(tx pgx.Tx, store Store) error {
+		_, err := tx.Exec(ctx, "DELETE FROM refresh_tokens WHERE user_id = $1", userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM session_families WHERE user_id = $1", userID)
+		if err != nil {
+			return err
+		}
+
+		revoked, err = store.DeleteUser(ctx, userID, nil)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
 
-	return err
+	return revoked, nil
 }
 
-func (sm *DBSessionManager) findSession(ctx context.Context, tokenString string) (*Session, error) {
-	var sess Session
-	err := sm.pgPool.QueryRow(
-		ctx,
-		"SELECT id, user_id, expires_at FROM sessions WHERE id=$1",
-		tokenString,
-	).Scan(&sess.ID, &sess.UserID, &sess.ExpiresAt)
+func (sm *DBSessionManager) CountActiveSessions(ctx context.Context) (int, error) {
+	return sm.store.Count(ctx)
+}
 
-	if err == pgx.ErrNoRows {
-		return nil, fmt.Errorf("session %s not found", tokenString)
-	} else if err != nil {
-		return nil, err
+// RemoveExpiredSessions deletes expired sessions and refresh tokens, and the
+// session families left without refresh tokens.
+func (sm *DBSessionManager) RemoveExpiredSessions(ctx context.Context) error {
+	err := sm.store.DeleteExpired(ctx)
+	if err != nil {
+		return err
 	}
 
-	return &sess, err
-}
+	sm.forgetSeen()
 
-func (sm *DBSessionManager) RemoveExpiredSessions(ctx context.Context) error {
-	_, err := sm.pgPool.Exec(
+	_, err = sm.pgPool.Exec(ctx, "DELETE FROM refresh_tokens WHERE expires_at < NOW()")
+	if err != nil {
+		return err
+	}
+
+	_, err = sm.pgPool.Exec(
 		ctx,
-		`DELETE FROM sessions
-		WHERE expires_at < EXTRACT(EPOCH FROM NOW() AT TIME ZONE 'UTC')`,
+		`DELETE FROM session_families sf
+		WHERE NOT EXISTS (
+			SELECT 1 FROM refresh_tokens rt
+			WHERE rt.family_id = sf.id AND rt.revoked_at IS NULL
+		)`,
 	)
 
 	return err
 }
+
+// inTx runs f in a transaction together with a view of the store whose writes
+// belong to it, and commits when f succeeds.
+func (sm *DBSessionManager) inTx(ctx context.Context, f func(tx pgx.Tx, store Store) error) error {
+	tx, err := sm.pgPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	store, done := sm.store.WithTx(tx)
+	committed := false
+	defer func() {
+		_ = tx.Rollback(ctx)
+		done(committed)
+	}()
+
+	err = f(tx, store)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MemoryStore keeps sessions in the memory of the process. Sessions are lost
+// on restart and are not shared between server instances, so the users of a
+// restarted server have to refresh their access tokens.
+type MemoryStore struct {
+	mu       *sync.RWMutex
+	sessions map[string]*Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu:       &sync.RWMutex{},
+		sessions: make(map[string]*Session, 5),
+	}
+}
+
+func (ms *MemoryStore) Add(_ context.Context, sess *Session) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.sessions[sess.ID] = stored(sess)
+	return nil
+}
+
+func (ms *MemoryStore) Get(_ context.Context, id string) (*Session, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	sess, ok := ms.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	return stored(sess), nil
+}
+
+func (ms *MemoryStore) Delete(_ context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.sessions, id)
+	return nil
+}
+
+func (ms *MemoryStore) DeleteFamily(_ context.Context, familyID string) error {
+	ms.deleteWhere(func(sess *Session) bool {
+		return sess.FamilyID == familyID
+	})
+	return nil
+}
+
+func (ms *MemoryStore) DeleteUser(_ context.Context, userID string, keep *Session) (int64, error) {
+	deleted := ms.deleteWhere(func(sess *Session) bool {
+		return sess.UserID == userID && !keeps(keep, sess)
+	})
+	return deleted, nil
+}
+
+func (ms *MemoryStore) DeleteExpired(_ context.Context) error {
+	now := time.Now().Unix()
+	ms.deleteWhere(func(sess *Session) bool {
+		return sess.ExpiresAt < now
+	})
+	return nil
+}
+
+func (ms *MemoryStore) Count(_ context.Context) (int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	now := time.Now().Unix()
+	count := 0
+	for _, sess := range ms.sessions {
+		if sess.ExpiresAt >= now {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// WithTx returns a view of the store that holds back added sessions until tx
+// commits. Deletions take effect at once.
+func (ms *MemoryStore) WithTx(_ pgx.Tx) (Store, func(committed bool)) {
+	mt := &memoryTx{MemoryStore: ms}
+	return mt, mt.done
+}
+
+// memoryTx is a MemoryStore view whose added sessions wait for a commit.
+type memoryTx struct {
+	*MemoryStore
+	added []*Session
+}
+
+func (mt *memoryTx) Add(_ context.Context, sess *Session) error {
+	mt.added = append(mt.added, stored(sess))
+	return nil
+}
+
+func (mt *memoryTx) WithTx(_ pgx.Tx) (Store, func(committed bool)) {
+	return mt, func(bool) {}
+}
+
+func (mt *memoryTx) done(committed bool) {
+	if !committed {
+		return
+	}
+
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	for _, sess := range mt.added {
+		mt.sessions[sess.ID] = sess
+	}
+}
+
+// deleteWhere deletes the sessions matching the predicate and returns how
+// many there were.
+func (ms *MemoryStore) deleteWhere(match func(*Session) bool) int64 {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var deleted int64
+	for id, sess := range ms.sessions {
+		if match(sess) {
+			delete(ms.sessions, id)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// keeps reports whether DeleteUser leaves sess alone because of keep.
+func keeps(keep *Session, sess *Session) bool {
+	if keep == nil {
+		return false
+	}
+
+	return sess.ID == keep.ID || (len(keep.FamilyID) != 0 && sess.FamilyID == keep.FamilyID)
+}
+
+// stored copies the fields of sess that a store keeps, so callers cannot
+// change a stored session and the refresh token is never held.
+func stored(sess *Session) *Session {
+	return &Session{
+		ID:        sess.ID,
+		UserID:    sess.UserID,
+		ExpiresAt: sess.ExpiresAt,
+		FamilyID:  sess.FamilyID,
+	}
+}
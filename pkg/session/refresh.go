@@ -0,0 +1,169 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/teatah/rclone/pkg/user"
+)
+
+// DefaultRefreshTTL is how long a refresh token stays valid when
+// DBSessionManager.RefreshTTL is not changed.
+const DefaultRefreshTTL = 30 * 24 * time.Hour
+
+var ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+
+// RefreshReuseError is returned when a refresh token is presented again after
+// it was exchanged. The token may have been stolen, so by the time the error
+// is returned every session and refresh token of its family is revoked.
+type RefreshReuseError struct {
+	UserID   string `json:"userId"`
+	FamilyID string `json:"familyId"`
+}
+
+func (e *RefreshReuseError) Error() string {
+	return "refresh token was already used, its sessions have been revoked"
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Refresh exchanges a refresh token for a new session and a new refresh token
+// of the same family. Each refresh token can be exchanged once.
+func (sm *DBSessionManager) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+	var (
+		sess     *Session
+		reuseErr *RefreshReuseError
+	)
+	err := sm.inTx(ctx, func(tx pgx.Tx, store Store) error {
+		var (
+			tokenID   string
+			familyID  string
+			expiresAt time.Time
+			usedAt    *time.Time
+			revokedAt *time.Time
+			u         = &user.User{}
+		)
+		err := tx.QueryRow(
+			ctx,
+			`SELECT rt.id, rt.family_id, rt.expires_at, rt.used_at, rt.revoked_at,
+				users.id, users.username, users.banned
+			FROM refresh_tokens rt
+			INNER JOIN users ON users.id = rt.user_id
+			WHERE rt.token_hash = $1
+			FOR UPDATE OF rt`,
+			hashRefreshToken(refreshToken),
+		).Scan(&tokenID, &familyID, &expiresAt, &usedAt, &revokedAt, &u.ID, &u.Username, &u.Banned)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case revokedAt != nil:
+			return ErrRefreshTokenInvalid
+		case usedAt != nil:
+			// The revocation commits; the caller gets reuseErr afterwards.
+			reuseErr = &RefreshReuseError{UserID: u.ID, FamilyID: familyID}
+			return revokeFamily(ctx, tx, store, familyID)
+		case time.Now().After(expiresAt):
+			return ErrRefreshTokenInvalid
+		case u.Banned:
+			return user.ErrUserBanned
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1", tokenID)
+		if err != nil {
+			return err
+		}
+
+		err = refreshFamily(ctx, tx, familyID)
+		if err != nil {
+			return err
+		}
+
+		sess, err = NewSession(u)
+		if err != nil {
+			return err
+		}
+		sess.FamilyID = familyID
+
+		sess.RefreshToken, err = sm.addRefreshToken(ctx, tx, sess)
+		if err != nil {
+			return err
+		}
+
+		return store.Add(ctx, sess)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reuseErr != nil {
+		return nil, reuseErr
+	}
+
+	return sess, nil
+}
+
+// addRefreshToken stores the hash of a new refresh token for the session's
+// family and returns the token.
+func (sm *DBSessionManager) addRefreshToken(ctx context.Context, tx pgx.Tx, sess *Session) (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO refresh_tokens (id, family_id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		uuid.NewString(),
+		sess.FamilyID,
+		sess.UserID,
+		hashRefreshToken(refreshToken),
+		time.Now().Add(sm.RefreshTTL),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
+}
+
+// revokeFamily revokes the refresh tokens of the family and ends its sessions
+// in tx.
+func revokeFamily(ctx context.Context, tx pgx.Tx, store Store, familyID string) error {
+	_, err := tx.Exec(ctx, "DELETE FROM session_families WHERE id = $1", familyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		"UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL",
+		familyID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return store.DeleteFamily(ctx, familyID)
+}
+
+// hashRefreshToken hashes a refresh token for storage. The tokens are random,
+// so a plain SHA-256 is enough.
+func hashRefreshToken(refreshToken string) []byte {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return sum[:]
+}
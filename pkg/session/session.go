@@ -18,6 +18,12 @@ type Session struct {
 	ID        string
 	UserID    string
 	ExpiresAt int64
+	// FamilyID groups the sessions and refresh tokens descending from one
+	// login.
+	FamilyID string
+	// RefreshToken is only known right after the session is created; the
+	// database keeps its hash.
+	RefreshToken string
 }
 
 func NewSession(user *user.User) (*Session, error) {
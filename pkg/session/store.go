@@ -0,0 +1,135 @@
+package session
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Store keeps the sessions behind access tokens. Refresh tokens and session
+// families stay in Postgres whichever store is used.
+type Store interface {
+	Add(ctx context.Context, sess *Session) error
+	// Get returns the session with the given ID or ErrSessionNotFound.
+	Get(ctx context.Context, id string) (*Session, error)
+	Delete(ctx context.Context, id string) error
+	DeleteFamily(ctx context.Context, familyID string) error
+	// DeleteUser deletes the sessions of the user, except keep and the
+	// sessions of its family when keep is not nil, and returns how many
+	// were deleted.
+	DeleteUser(ctx context.Context, userID string, keep *Session) (int64, error)
+	DeleteExpired(ctx context.Context) error
+	// Count returns the number of sessions that have not expired.
+	Count(ctx context.Context) (int, error)
+	// WithTx returns a view of the store whose writes belong to tx, and a
+	// function to call once tx has committed or rolled back. Sessions added
+	// through the view are not used before tx commits. Stores outside
+	// Postgres delete sessions at once, so a failed commit leaves them
+	// logged out rather than their access tokens working.
+	WithTx(tx pgx.Tx) (Store, func(committed bool))
+}
+
+// pgConn is what PGStore runs its statements on: the pool or a transaction.
+type pgConn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// PGStore keeps sessions in the Postgres sessions table.
+type PGStore struct {
+	db pgConn
+}
+
+func NewPGStore(pgPool *pgxpool.Pool) *PGStore {
+	return &PGStore{
+		db: pgPool,
+	}
+}
+
+// WithTx returns a store running its statements in tx, so they commit and
+// roll back with it.
+func (ps *PGStore) WithTx(tx pgx.Tx) (Store, func(committed bool)) {
+	return &PGStore{db: tx}, func(bool) {}
+}
+
+func (ps *PGStore) Add(ctx context.Context, sess *Session) error {
+	_, err := ps.db.Exec(
+		ctx,
+		"INSERT INTO sessions (id, user_id, expires_at, family_id) values ($1, $2, $3, $4)",
+		sess.ID,
+		sess.UserID,
+		sess.ExpiresAt,
+		sess.FamilyID,
+	)
+
+	return err
+}
+
+func (ps *PGStore) Get(ctx context.Context, id string) (*Session, error) {
+	var sess Session
+	err := ps.db.QueryRow(
+		ctx,
+		"SELECT id, user_id, expires_at, COALESCE(family_id, '') FROM sessions WHERE id=$1",
+		id,
+	).Scan(&sess.ID, &sess.UserID, &sess.ExpiresAt, &sess.FamilyID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &sess, nil
+}
+
+func (ps *PGStore) Delete(ctx context.Context, id string) error {
+	_, err := ps.db.Exec(ctx, "DELETE FROM sessions WHERE id = $1", id)
+	return err
+}
+
+func (ps *PGStore) DeleteFamily(ctx context.Context, familyID string) error {
+	_, err := ps.db.Exec(ctx, "DELETE FROM sessions WHERE family_id = $1", familyID)
+	return err
+}
+
+func (ps *PGStore) DeleteUser(ctx context.Context, userID string, keep *Session) (int64, error) {
+	if keep == nil {
+		tag, err := ps.db.Exec(ctx, "DELETE FROM sessions WHERE user_id = $1", userID)
+		return tag.RowsAffected(), err
+	}
+
+	tag, err := ps.db.Exec(
+		ctx,
+		"DELETE FROM sessions WHERE user_id = $1 AND id <> $2 AND family_id IS DISTINCT FROM $3",
+		userID,
+		keep.ID,
+		keep.FamilyID,
+	)
+
+	return tag.RowsAffected(), err
+}
+
+func (ps *PGStore) DeleteExpired(ctx context.Context) error {
+	_, err := ps.db.Exec(
+		ctx,
+		`DELETE FROM sessions
+		WHERE expires_at < EXTRACT(EPOCH FROM NOW() AT TIME ZONE 'UTC')`,
+	)
+
+	return err
+}
+
+func (ps *PGStore) Count(ctx context.Context) (int, error) {
+	var count int
+	err := ps.db.QueryRow(
+		ctx,
+		`SELECT COUNT(*)
+		FROM sessions
+		WHERE expires_at >= EXTRACT(EPOCH FROM NOW() AT TIME ZONE 'UTC')`,
+	).Scan(&count)
+
+	return count, err
+}
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// emailAudience is appended to the audience of email verification tokens, so
+// they are never accepted as access tokens.
+const emailAudience = "/email-verification"
+
+// EmailClaims confirm that the user with ID Subject owns Email.
+type EmailClaims struct {
+	*jwt.StandardClaims
+
+	Email string `json:"email"`
+}
+
+// CreateEmailToken signs a token for the link that verifies the email address
+// of the user. It is valid for ttl.
+func CreateEmailToken(userID string, email string, ttl time.Duration) (string, error) {
+	issueTime := time.Now()
+
+	claims := &EmailClaims{
+		StandardClaims: &jwt.StandardClaims{
+			Subject:   userID,
+			Issuer:    settings.Issuer,
+			Audience:  settings.Audience + emailAudience,
+			IssuedAt:  issueTime.Unix(),
+			NotBefore: issueTime.Unix(),
+			ExpiresAt: issueTime.Add(ttl).Unix(),
+		},
+		Email: email,
+	}
+
+	key := keys.Signing()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.private)
+	if err != nil {
+		return "", fmt.Errorf("error creating email token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// ParseEmailToken verifies an email verification token like ParseJwt does an
+// access token and returns its claims.
+func ParseEmailToken(token string) (*EmailClaims, error) {
+	if len(token) == 0 {
+		return nil, &ValidationError{Err: ErrTokenMissing}
+	}
+
+	claims := &EmailClaims{}
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(token, claims, keys.keyFunc)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	if claims.StandardClaims == nil || len(claims.Subject) == 0 || len(claims.Email) == 0 {
+		return nil, &ValidationError{Err: ErrTokenMalformed, Detail: "required claims are missing"}
+	}
+
+	err = settings.validateStandard(claims.StandardClaims, settings.Audience+emailAudience, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
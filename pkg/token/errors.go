@@ -0,0 +1,55 @@
+package token
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrTokenMissing     = errors.New("token is missing")
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrTokenUnknownKey  = errors.New("token is signed with an unknown key")
+	ErrTokenSignature   = errors.New("token signature is invalid")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrTokenIssuer      = errors.New("token issuer is not accepted")
+	ErrTokenAudience    = errors.New("token audience is not accepted")
+)
+
+// ValidationError is returned by ParseJwt for a token that must not be
+// accepted. Err is one of the ErrToken errors and Detail optionally explains
+// it further.
+type ValidationError struct {
+	Err    error
+	Detail string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Detail) == 0 {
+		return e.Err.Error()
+	}
+
+	return e.Err.Error() + ": " + e.Detail
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// parseError translates the errors of the jwt parser.
+func parseError(err error) error {
+	var jwtErr *jwt.ValidationError
+	if !errors.As(err, &jwtErr) {
+		return &ValidationError{Err: ErrTokenMalformed, Detail: err.Error()}
+	}
+
+	switch {
+	case jwtErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+		return &ValidationError{Err: ErrTokenUnknownKey, Detail: jwtErr.Error()}
+	case jwtErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return &ValidationError{Err: ErrTokenSignature}
+	}
+
+	return &ValidationError{Err: ErrTokenMalformed, Detail: jwtErr.Error()}
+}
@@ -0,0 +1,239 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// SecretKeyID is the kid of the key made from a shared secret. Tokens issued
+// without a kid header are verified with it.
+const SecretKeyID = "secret"
+
+// Key is a key that verifies tokens and, when its private part is known,
+// signs them.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private any
+	public  any
+}
+
+// CanSign reports whether the key holds the private part needed for signing.
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+// NewSecretKey makes an HS256 key from a shared secret. Its ID is
+// SecretKeyID.
+func NewSecretKey(secret []byte) *Key {
+	return &Key{ID: SecretKeyID, Method: jwt.SigningMethodHS256, private: secret, public: secret}
+}
+
+// LoadKeyFile reads an RSA or Ed25519 key from a PEM file. Private keys sign
+// with RS256 or EdDSA; public keys only verify, which is how retired keys are
+// kept during rotation. The key ID is the file name up to its first dot, so
+// key.pem and key.pub.pem share an ID.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	id, _, _ := strings.Cut(filepath.Base(path), ".")
+
+	key, err := parseKey(id, data)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+
+	return key, nil
+}
+
+func parseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var (
+		parsed any
+		err    error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, private: k, public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, public: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, private: k, public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, public: k}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+}
+
+// LoadKeySet builds the key set from key files and an optional shared secret.
+// The key files come first, so without signingID new tokens are signed with
+// the first private key file while the secret still verifies older tokens.
+func LoadKeySet(signingID string, secret string, paths []string) (*KeySet, error) {
+	var keys []*Key
+	for _, path := range paths {
+		k, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	if len(secret) != 0 {
+		keys = append(keys, NewSecretKey([]byte(secret)))
+	}
+
+	return NewKeySet(signingID, keys...)
+}
+
+// KeySet holds the keys that verify tokens and the one that signs new ones.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	order   []*Key
+}
+
+// NewKeySet makes a key set that signs with the key called signingID, or with
+// the first key able to sign when signingID is empty.
+func NewKeySet(signingID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		_, exists := ks.keys[k.ID]
+		if exists {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+		ks.order = append(ks.order, k)
+
+		if ks.signing == nil && len(signingID) == 0 && k.CanSign() {
+			ks.signing = k
+		}
+	}
+
+	if len(signingID) != 0 {
+		ks.signing = ks.keys[signingID]
+		if ks.signing == nil {
+			return nil, fmt.Errorf("signing key %q not found", signingID)
+		}
+	}
+
+	if ks.signing == nil || !ks.signing.CanSign() {
+		return nil, errors.New("no private key to sign tokens with")
+	}
+
+	return ks, nil
+}
+
+// Signing returns the key new tokens are signed with.
+func (ks *KeySet) Signing() *Key {
+	return ks.signing
+}
+
+// Lookup returns the key with the given ID. An empty ID finds the secret key,
+// which signed the tokens issued before key IDs were introduced.
+func (ks *KeySet) Lookup(id string) (*Key, bool) {
+	if len(id) == 0 {
+		id = SecretKeyID
+	}
+
+	k, ok := ks.keys[id]
+	return k, ok
+}
+
+// keyFunc verifies that the token is signed with the algorithm of the key
+// named by its kid header and returns that key.
+func (ks *KeySet) keyFunc(t *jwt.Token) (any, error) {
+	id, _ := t.Header["kid"].(string)
+
+	k, ok := ks.Lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", t.Method.Alg(), k.ID)
+	}
+
+	return k.public, nil
+}
+
+// JWK is the public part of a key in JSON Web Key form.
+type JWK struct {
+	KeyType string `json:"kty"`
+	ID      string `json:"kid"`
+	Use     string `json:"use"`
+	Alg     string `json:"alg"`
+	N       string `json:"n,omitempty"`
+	E       string `json:"e,omitempty"`
+	Curve   string `json:"crv,omitempty"`
+	X       string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set. Secret keys are left out since
+// they cannot be shared.
+func (ks *KeySet) JWKS() *JWKS {
+	set := &JWKS{Keys: make([]JWK, 0, len(ks.order))}
+	for _, k := range ks.order {
+		jwk := JWK{ID: k.ID, Use: "sig", Alg: k.Method.Alg()}
+
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = encodeJWKInt(pub.N)
+			jwk.E = encodeJWKInt(big.NewInt(int64(pub.E)))
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func encodeJWKInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
@@ -0,0 +1,182 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// newTestRSAKey returns a signing RSA key and the PEM encoding of its public
+// part.
+func newTestRSAKey(t *testing.T, id string) (*Key, []byte) {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := parseKey(id, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(private),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+}
+
+func newTestClaims() *TokenClaims {
+	now := time.Now()
+
+	return &TokenClaims{
+		StandardClaims: &jwt.StandardClaims{
+			Issuer:    testSettings.Issuer,
+			Audience:  testSettings.Audience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(time.Minute).Unix(),
+		},
+		User: UserClaims{Username: "alice", ID: "1"},
+	}
+}
+
+func TestKeyFuncRejectsAlgorithmMismatch(t *testing.T) {
+	rsaKey, publicPEM := newTestRSAKey(t, "rsa")
+	secretKey := NewSecretKey([]byte("test secret"))
+
+	ks, err := NewKeySet(rsaKey.ID, rsaKey, secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configureTest(t, ks, testSettings)
+
+	// HS256 keyed with the published RSA public key must not verify under
+	// the RSA key ID.
+	forger := &Key{ID: rsaKey.ID, private: publicPEM}
+	forged := signTestToken(t, forger, jwt.SigningMethodHS256, newTestClaims())
+	_, err = ParseJwt(forged)
+	checkValidationError(t, err, ErrTokenUnknownKey)
+
+	// Nor may the RSA key sign under the ID of the secret key.
+	misnamed := &Key{ID: SecretKeyID, private: rsaKey.private}
+	_, err = ParseJwt(signTestToken(t, misnamed, jwt.SigningMethodRS256, newTestClaims()))
+	checkValidationError(t, err, ErrTokenUnknownKey)
+
+	for _, alg := range []string{"HS256", "RS256"} {
+		token := &jwt.Token{
+			Method: jwt.GetSigningMethod(alg),
+			Header: map[string]any{"alg": alg, "kid": rsaKey.ID},
+		}
+		k, err := ks.keyFunc(token)
+		switch {
+		case alg == rsaKey.Method.Alg() && (err != nil || k != rsaKey.public):
+			t.Errorf("%s token for key %q: got key %v and error %v, want the public key", alg, rsaKey.ID, k, err)
+		case alg != rsaKey.Method.Alg() && err == nil:
+			t.Errorf("%s token for key %q: got no error", alg, rsaKey.ID)
+		}
+	}
+}
+
+func TestKeyFuncSelectsKeyByID(t *testing.T) {
+	oldKey, _ := newTestRSAKey(t, "old")
+	newKey, _ := newTestRSAKey(t, "new")
+	secretKey := NewSecretKey([]byte("test secret"))
+
+	// The old key is retired: only its public part is kept to verify the
+	// tokens it signed.
+	retired := &Key{ID: oldKey.ID, Method: oldKey.Method, public: oldKey.public}
+	ks, err := NewKeySet(newKey.ID, newKey, retired, secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configureTest(t, ks, testSettings)
+
+	tests := []struct {
+		name   string
+		key    *Key
+		method jwt.SigningMethod
+		err    error
+	}{
+		{"signing key", newKey, jwt.SigningMethodRS256, nil},
+		{"retired key", oldKey, jwt.SigningMethodRS256, nil},
+		{"secret key", secretKey, jwt.SigningMethodHS256, nil},
+		{"without kid", &Key{private: secretKey.private}, jwt.SigningMethodHS256, nil},
+		{"unknown key", &Key{ID: "unknown", private: newKey.private}, jwt.SigningMethodRS256, ErrTokenUnknownKey},
+		{"key of another id", &Key{ID: newKey.ID, private: oldKey.private}, jwt.SigningMethodRS256, ErrTokenSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(tt.method, newTestClaims())
+			if len(tt.key.ID) != 0 {
+				token.Header["kid"] = tt.key.ID
+			}
+			tokenString, err := token.SignedString(tt.key.private)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = ParseJwt(tokenString)
+			checkValidationError(t, err, tt.err)
+		})
+	}
+}
+
+func TestNewKeySet(t *testing.T) {
+	rsaKey, publicPEM := newTestRSAKey(t, "rsa")
+	publicKey, err := parseKey("public", publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey := &Key{ID: "ed", Method: jwt.SigningMethodEdDSA, private: edPrivate, public: edPrivate.Public()}
+
+	tests := []struct {
+		name      string
+		signingID string
+		keys      []*Key
+		signing   string
+		ok        bool
+	}{
+		{"first private key", "", []*Key{publicKey, rsaKey, edKey}, rsaKey.ID, true},
+		{"named key", edKey.ID, []*Key{rsaKey, edKey}, edKey.ID, true},
+		{"unknown signing key", "missing", []*Key{rsaKey}, "", false},
+		{"public signing key", publicKey.ID, []*Key{publicKey, rsaKey}, "", false},
+		{"public keys only", "", []*Key{publicKey}, "", false},
+		{"duplicate id", "", []*Key{rsaKey, rsaKey}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := NewKeySet(tt.signingID, tt.keys...)
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("got key set signing with %q, want an error", ks.Signing().ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if ks.Signing().ID != tt.signing {
+				t.Errorf("signing key = %q, want %q", ks.Signing().ID, tt.signing)
+			}
+		})
+	}
+}
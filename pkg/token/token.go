@@ -2,7 +2,6 @@ package token
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -22,24 +21,60 @@ type UserClaims struct {
 	ID       string `json:"id"`
 }
 
-var secret = []byte("secret")
+// Settings control the claims of issued tokens and how strictly they are
+// checked.
+type Settings struct {
+	// TTL is how long new tokens are valid.
+	TTL time.Duration
+	// Issuer and Audience are set on new tokens and required on verified ones.
+	Issuer   string
+	Audience string
+	// ClockSkew is the tolerance applied to exp, nbf and iat to allow for
+	// clocks that are slightly off.
+	ClockSkew time.Duration
+}
+
+// DefaultSettings are used until Configure is called.
+var DefaultSettings = Settings{
+	TTL:       15 * time.Minute,
+	Issuer:    "rclone",
+	Audience:  "rclone",
+	ClockSkew: 30 * time.Second,
+}
+
+var (
+	keys, _  = NewKeySet("", NewSecretKey([]byte("secret")))
+	settings = DefaultSettings
+)
+
+// Configure sets the keys used to sign and verify tokens and the settings of
+// the claims.
+func Configure(keySet *KeySet, s Settings) {
+	keys = keySet
+	settings = s
+}
 
 func (tc *TokenClaims) CreateJwt(u *user.User) (string, error) {
 	issueTime := time.Now()
-	expTime := issueTime.Add(time.Minute * 1 / 2).Unix()
+	expTime := issueTime.Add(settings.TTL).Unix()
 
 	tc.User = UserClaims{
 		Username: u.Username,
 		ID:       u.ID,
 	}
 	tc.StandardClaims = &jwt.StandardClaims{
+		Issuer:    settings.Issuer,
+		Audience:  settings.Audience,
 		IssuedAt:  issueTime.Unix(),
+		NotBefore: issueTime.Unix(),
 		ExpiresAt: expTime,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tc)
+	key := keys.Signing()
+	token := jwt.NewWithClaims(key.Method, tc)
+	token.Header["kid"] = key.ID
 
-	tokenString, err := token.SignedString(secret)
+	tokenString, err := token.SignedString(key.private)
 	if err != nil {
 		return "", fmt.Errorf("error creating jwt: %w", err)
 	}
@@ -47,22 +82,65 @@ func (tc *TokenClaims) CreateJwt(u *user.User) (string, error) {
 	return tokenString, nil
 }
 
+// ParseJwt verifies the signature and the claims of the token and returns
+// them. Any failure is a *ValidationError wrapping one of the ErrToken
+// errors.
 func ParseJwt(token string) (*TokenClaims, error) {
+	if len(token) == 0 {
+		return nil, &ValidationError{Err: ErrTokenMissing}
+	}
+
 	claims := &TokenClaims{}
-	t, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header)
-		}
-		return secret, nil
-	})
-
-	if err != nil || !t.Valid {
-		log.Println("token validation skipped - will be valided according to data from the database")
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(token, claims, keys.keyFunc)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	err = settings.validate(claims, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
 	return claims, nil
 }
 
+// validate checks the registered claims of a token whose signature is
+// already verified.
+func (s Settings) validate(claims *TokenClaims, now time.Time) error {
+	if claims.StandardClaims == nil || len(claims.User.ID) == 0 {
+		return &ValidationError{Err: ErrTokenMalformed, Detail: "required claims are missing"}
+	}
+
+	return s.validateStandard(claims.StandardClaims, s.Audience, now)
+}
+
+// validateStandard checks the registered claims against the settings and the
+// audience the token is meant for.
+func (s Settings) validateStandard(sc *jwt.StandardClaims, audience string, now time.Time) error {
+	if sc.ExpiresAt == 0 {
+		return &ValidationError{Err: ErrTokenMalformed, Detail: "required claims are missing"}
+	}
+
+	skew := int64(s.ClockSkew / time.Second)
+	unix := now.Unix()
+
+	switch {
+	case unix > sc.ExpiresAt+skew:
+		return &ValidationError{Err: ErrTokenExpired}
+	case sc.NotBefore != 0 && unix+skew < sc.NotBefore:
+		return &ValidationError{Err: ErrTokenNotValidYet}
+	case sc.IssuedAt != 0 && unix+skew < sc.IssuedAt:
+		return &ValidationError{Err: ErrTokenNotValidYet, Detail: "issued in the future"}
+	case sc.Issuer != s.Issuer:
+		return &ValidationError{Err: ErrTokenIssuer, Detail: fmt.Sprintf("got %q", sc.Issuer)}
+	case sc.Audience != audience:
+		return &ValidationError{Err: ErrTokenAudience, Detail: fmt.Sprintf("got %q", sc.Audience)}
+	}
+
+	return nil
+}
+
 func TokenFromHeader(r *http.Request) string {
 	tokenString := r.Header.Get("Authorization")
 	tokenString = strings.ReplaceAll(tokenString, "Bearer ", "")
@@ -0,0 +1,235 @@
+package token
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/teatah/rclone/pkg/user"
+)
+
+var testSettings = Settings{
+	TTL:       time.Minute,
+	Issuer:    "rclone",
+	Audience:  "rclone",
+	ClockSkew: 30 * time.Second,
+}
+
+// configureTest configures the package for the test and restores the previous
+// configuration when it ends.
+func configureTest(t *testing.T, keySet *KeySet, s Settings) {
+	t.Helper()
+
+	prevKeys, prevSettings := keys, settings
+	t.Cleanup(func() {
+		Configure(prevKeys, prevSettings)
+	})
+
+	Configure(keySet, s)
+}
+
+// checkValidationError fails the test unless err is a *ValidationError
+// wrapping want, or is nil when want is nil.
+func checkValidationError(t *testing.T, err error, want error) {
+	t.Helper()
+
+	if want == nil {
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		return
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, want) {
+		t.Errorf("got error %v, want a *ValidationError wrapping %q", err, want)
+	}
+}
+
+func TestValidateStandard(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	unix := now.Unix()
+
+	tests := []struct {
+		name     string
+		claims   jwt.StandardClaims
+		audience string
+		err      error
+	}{
+		{"valid", jwt.StandardClaims{ExpiresAt: unix + 60, NotBefore: unix, IssuedAt: unix, Issuer: "rclone", Audience: "rclone"}, "rclone", nil},
+		{"without nbf and iat", jwt.StandardClaims{ExpiresAt: unix + 60, Issuer: "rclone", Audience: "rclone"}, "rclone", nil},
+		{"without exp", jwt.StandardClaims{NotBefore: unix, IssuedAt: unix, Issuer: "rclone", Audience: "rclone"}, "rclone", ErrTokenMalformed},
+		{"expired", jwt.StandardClaims{ExpiresAt: unix - 31, Issuer: "rclone", Audience: "rclone"}, "rclone", ErrTokenExpired},
+		{"expired within clock skew", jwt.StandardClaims{ExpiresAt: unix - 30, Issuer: "rclone", Audience: "rclone"}, "rclone", nil},
+		{"not valid yet", jwt.StandardClaims{ExpiresAt: unix + 120, NotBefore: unix + 31, Issuer: "rclone", Audience: "rclone"}, "rclone", ErrTokenNotValidYet},
+		{"not valid yet within clock skew", jwt.StandardClaims{ExpiresAt: unix + 120, NotBefore: unix + 30, Issuer: "rclone", Audience: "rclone"}, "rclone", nil},
+		{"issued in the future", jwt.StandardClaims{ExpiresAt: unix + 120, IssuedAt: unix + 31, Issuer: "rclone", Audience: "rclone"}, "rclone", ErrTokenNotValidYet},
+		{"issued in the future within clock skew", jwt.StandardClaims{ExpiresAt: unix + 120, IssuedAt: unix + 30, Issuer: "rclone", Audience: "rclone"}, "rclone", nil},
+		{"other issuer", jwt.StandardClaims{ExpiresAt: unix + 60, Issuer: "evil", Audience: "rclone"}, "rclone", ErrTokenIssuer},
+		{"without issuer", jwt.StandardClaims{ExpiresAt: unix + 60, Audience: "rclone"}, "rclone", ErrTokenIssuer},
+		{"other audience", jwt.StandardClaims{ExpiresAt: unix + 60, Issuer: "rclone", Audience: "other"}, "rclone", ErrTokenAudience},
+		{"without audience", jwt.StandardClaims{ExpiresAt: unix + 60, Issuer: "rclone"}, "rclone", ErrTokenAudience},
+		{"email audience", jwt.StandardClaims{ExpiresAt: unix + 60, Issuer: "rclone", Audience: "rclone" + emailAudience}, "rclone", ErrTokenAudience},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testSettings.validateStandard(&tt.claims, tt.audience, now)
+			checkValidationError(t, err, tt.err)
+		})
+	}
+}
+
+func TestValidateRequiresUser(t *testing.T) {
+	now := time.Now()
+	sc := &jwt.StandardClaims{ExpiresAt: now.Add(time.Minute).Unix(), Issuer: "rclone", Audience: "rclone"}
+
+	tests := []struct {
+		name   string
+		claims *TokenClaims
+		err    error
+	}{
+		{"valid", &TokenClaims{StandardClaims: sc, User: UserClaims{Username: "alice", ID: "1"}}, nil},
+		{"without user id", &TokenClaims{StandardClaims: sc, User: UserClaims{Username: "alice"}}, ErrTokenMalformed},
+		{"without registered claims", &TokenClaims{User: UserClaims{Username: "alice", ID: "1"}}, ErrTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkValidationError(t, testSettings.validate(tt.claims, now), tt.err)
+		})
+	}
+}
+
+func TestParseJwt(t *testing.T) {
+	ks, err := NewKeySet("", NewSecretKey([]byte("test secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configureTest(t, ks, testSettings)
+
+	tc := &TokenClaims{}
+	tokenString, err := tc.CreateJwt(&user.User{ID: "1", Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseJwt(tokenString)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if claims.User.ID != "1" || claims.User.Username != "alice" {
+		t.Errorf("user = %+v, want alice with id 1", claims.User)
+	}
+
+	_, err = ParseJwt("")
+	checkValidationError(t, err, ErrTokenMissing)
+
+	_, err = ParseJwt("not a token")
+	checkValidationError(t, err, ErrTokenMalformed)
+
+	dot := strings.LastIndex(tokenString, ".")
+	_, err = ParseJwt(tokenString[:dot+1] + "AAAA" + tokenString[dot+5:])
+	checkValidationError(t, err, ErrTokenSignature)
+
+	otherKeys, err := NewKeySet("", NewSecretKey([]byte("other secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(otherKeys, testSettings)
+	_, err = ParseJwt(tokenString)
+	checkValidationError(t, err, ErrTokenSignature)
+}
+
+func TestParseJwtRejectsExpiredToken(t *testing.T) {
+	ks, err := NewKeySet("", NewSecretKey([]byte("test secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configureTest(t, ks, testSettings)
+
+	issued := time.Now().Add(-2 * time.Minute)
+	tokenString := signTestToken(t, ks.Signing(), ks.Signing().Method, &TokenClaims{
+		StandardClaims: &jwt.StandardClaims{
+			Issuer:    testSettings.Issuer,
+			Audience:  testSettings.Audience,
+			IssuedAt:  issued.Unix(),
+			NotBefore: issued.Unix(),
+			ExpiresAt: issued.Add(testSettings.TTL).Unix(),
+		},
+		User: UserClaims{Username: "alice", ID: "1"},
+	})
+
+	_, err = ParseJwt(tokenString)
+	checkValidationError(t, err, ErrTokenExpired)
+}
+
+func TestEmailTokenIsNoAccessToken(t *testing.T) {
+	ks, err := NewKeySet("", NewSecretKey([]byte("test secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configureTest(t, ks, testSettings)
+
+	emailToken, err := CreateEmailToken("1", "alice@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseEmailToken(emailToken)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if claims.Subject != "1" || claims.Email != "alice@example.com" {
+		t.Errorf("claims = %+v, want subject 1 and alice@example.com", claims)
+	}
+
+	_, err = ParseJwt(emailToken)
+	if err == nil {
+		t.Fatal("email token accepted as an access token")
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("got error %v, want a *ValidationError", err)
+	}
+
+	// An email token carrying user claims still has the wrong audience.
+	forged := signTestToken(t, ks.Signing(), ks.Signing().Method, &TokenClaims{
+		StandardClaims: &jwt.StandardClaims{
+			Issuer:    testSettings.Issuer,
+			Audience:  testSettings.Audience + emailAudience,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		User: UserClaims{Username: "alice", ID: "1"},
+	})
+	_, err = ParseJwt(forged)
+	checkValidationError(t, err, ErrTokenAudience)
+
+	tc := &TokenClaims{}
+	accessToken, err := tc.CreateJwt(&user.User{ID: "1", Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseEmailToken(accessToken)
+	if err == nil {
+		t.Error("access token accepted as an email token")
+	}
+}
+
+// signTestToken signs claims with the private part of key under method, with
+// the key ID in the kid header.
+func signTestToken(t *testing.T, key *Key, method jwt.SigningMethod, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenString
+}
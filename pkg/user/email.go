@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MaxEmailLen is the width of the email column.
+const MaxEmailLen = 255
+
+var (
+	ErrEmailNotVerified      = errors.New("a verified email address is required")
+	ErrVerificationInvalid   = errors.New("verification link is invalid or expired")
+	ErrVerificationThrottled = errors.New("a verification link was sent recently")
+)
+
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// EmailStatus is the email address of a user as shown to them.
+type EmailStatus struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+}
+
+// CheckEmail returns why the email address is rejected, or nil. An empty
+// address is accepted since the email is optional.
+func CheckEmail(email string) *FieldError {
+	switch {
+	case len(email) == 0:
+		return nil
+	case len(email) > MaxEmailLen || !ValidEmail(email):
+		return &FieldError{Field: "email", Value: email, Msg: "must be a valid email address"}
+	}
+
+	return nil
+}
+
+// CanPost returns ErrEmailNotVerified when the policy requires a verified
+// email address to post and the user has none.
+func (ur *UserDBRepo) CanPost(u *User) error {
+	if ur.Policy.RequireVerifiedEmail && !u.EmailVerified {
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// SetEmail sets the email address of the user, or removes it when email is
+// empty. A new address is unverified unless verified is set; setting the
+// current address again keeps its state.
+func (ur *UserDBRepo) SetEmail(ctx context.Context, userID string, email string, verified bool) error {
+	return ur.execForUser(
+		ctx,
+		`UPDATE users
+		SET email = NULLIF($2, ''),
+			email_verified_at = CASE
+				WHEN $2 = '' THEN NULL
+				WHEN $3 THEN COALESCE(email_verified_at, NOW())
+				WHEN email IS NOT DISTINCT FROM $2 THEN email_verified_at
+			END
+		WHERE id = $1`,
+		userID,
+		email,
+		verified,
+	)
+}
+
+// VerifyEmail marks the email address of the user as verified. It returns
+// ErrVerificationInvalid when email is no longer the user's address.
+func (ur *UserDBRepo) VerifyEmail(ctx context.Context, userID string, email string) error {
+	tag, err := ur.pgPool.Exec(
+		ctx,
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1 AND email = $2",
+		userID,
+		email,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrVerificationInvalid
+	}
+
+	return nil
+}
+
+// MarkVerificationSent records that a verification link is being sent to the
+// user. It returns ErrVerificationThrottled when the previous one was sent
+// less than interval ago.
+func (ur *UserDBRepo) MarkVerificationSent(ctx context.Context, userID string, interval time.Duration) error {
+	tag, err := ur.pgPool.Exec(
+		ctx,
+		`UPDATE users SET email_verification_sent_at = NOW()
+		WHERE id = $1 AND (email_verification_sent_at IS NULL OR email_verification_sent_at < $2)`,
+		userID,
+		time.Now().Add(-interval),
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrVerificationThrottled
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package user
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxUsernameLen is the width of the username column.
+	MaxUsernameLen = 55
+	// MaxPasswordBytes is the longest password bcrypt hashes.
+	MaxPasswordBytes = 72
+)
+
+const DefaultUsernamePattern = `^[\p{L}\p{N}_-]+$`
+
+var defaultReservedNames = []string{
+	"admin", "administrator", "anonymous", "api", "deleted", "me", "mod",
+	"moderator", "null", "rclone", "root", "staff", "static", "support",
+	"system", "undefined",
+}
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// FieldError describes why the value of a request field was rejected.
+type FieldError struct {
+	Field string
+	Value string
+	Msg   string
+}
+
+func (fe *FieldError) Error() string {
+	return fe.Field + " " + fe.Msg
+}
+
+// ValidationError is returned when a request breaks the Policy. It holds one
+// error per rejected field.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, 0, len(ve.Errors))
+	for _, fe := range ve.Errors {
+		msgs = append(msgs, fe.Error())
+	}
+
+	return strings.Join(msgs, ", ")
+}
+
+// Policy holds the rules usernames and passwords are checked against when a
+// user registers, and what users need before they can post. Lengths are
+// counted in characters.
+type Policy struct {
+	UsernameMinLen  int
+	UsernameMaxLen  int
+	UsernamePattern *regexp.Regexp
+	PasswordMinLen  int
+	// RequireVerifiedEmail keeps users without a verified email address from
+	// posting and commenting.
+	RequireVerifiedEmail bool
+	reserved             map[string]bool
+	passwords            map[string]bool
+}
+
+// NewPolicy returns the default policy: usernames of 3 to 32 letters, digits,
+// underscores and dashes that are not reserved, and passwords of at least 8
+// characters that are not on the built-in list of common passwords.
+func NewPolicy() *Policy {
+	p := &Policy{
+		UsernameMinLen:  3,
+		UsernameMaxLen:  32,
+		UsernamePattern: regexp.MustCompile(DefaultUsernamePattern),
+		PasswordMinLen:  8,
+		reserved:        make(map[string]bool, len(defaultReservedNames)),
+		passwords:       make(map[string]bool),
+	}
+	p.Reserve(defaultReservedNames...)
+	p.BlockPasswords(strings.Fields(commonPasswords)...)
+
+	return p
+}
+
+// Reserve keeps the given names, and names differing from them only in case
+// or encoding, from being registered.
+func (p *Policy) Reserve(names ...string) {
+	for _, name := range names {
+		p.reserved[NormalizeUsername(name)] = true
+	}
+}
+
+// BlockPasswords rejects the given passwords regardless of case.
+func (p *Policy) BlockPasswords(passwords ...string) {
+	for _, password := range passwords {
+		p.passwords[strings.ToLower(password)] = true
+	}
+}
+
+// LoadPasswordList reads a list of passwords, one per line, such as a list of
+// breached passwords. Empty lines are skipped.
+func LoadPasswordList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password list: %w", err)
+	}
+	defer f.Close()
+
+	var passwords []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		password := strings.TrimSpace(scanner.Text())
+		if len(password) != 0 {
+			passwords = append(passwords, password)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password list: %w", err)
+	}
+
+	return passwords, nil
+}
+
+// Check reports every registration field that breaks the policy as a
+// *ValidationError.
+func (p *Policy) Check(username string, password string, email string) error {
+	var errs []*FieldError
+	for _, fe := range []*FieldError{
+		p.CheckUsername(username),
+		p.CheckPassword(username, password),
+		CheckEmail(email),
+	} {
+		if fe != nil {
+			errs = append(errs, fe)
+		}
+	}
+
+	if len(errs) != 0 {
+		return &ValidationError{Errors: errs}
+	}
+
+	return nil
+}
+
+// CheckUsername returns why the username is rejected, or nil.
+func (p *Policy) CheckUsername(username string) *FieldError {
+	reject := func(msg string) *FieldError {
+		return &FieldError{Field: "username", Value: username, Msg: msg}
+	}
+
+	length := utf8.RuneCountInString(username)
+	switch {
+	case length == 0:
+		return reject("is required")
+	case length < p.UsernameMinLen:
+		return reject(fmt.Sprintf("must be at least %d characters long", p.UsernameMinLen))
+	case length > p.UsernameMaxLen:
+		return reject(fmt.Sprintf("must be at most %d characters long", p.UsernameMaxLen))
+	case !p.UsernamePattern.MatchString(username):
+		return reject("contains characters that are not allowed")
+	case p.reserved[NormalizeUsername(username)]:
+		return reject("is reserved")
+	}
+
+	return nil
+}
+
+// CheckPassword returns why the password of the user is rejected, or nil. The
+// password is never included in the error.
+func (p *Policy) CheckPassword(username string, password string) *FieldError {
+	reject := func(msg string) *FieldError {
+		return &FieldError{Field: "password", Msg: msg}
+	}
+
+	switch {
+	case len(password) == 0:
+		return reject("is required")
+	case utf8.RuneCountInString(password) < p.PasswordMinLen:
+		return reject(fmt.Sprintf("must be at least %d characters long", p.PasswordMinLen))
+	case len(password) > MaxPasswordBytes:
+		return reject(fmt.Sprintf("must be at most %d bytes long", MaxPasswordBytes))
+	case p.passwords[strings.ToLower(password)]:
+		return reject("is too common")
+	case NormalizeUsername(password) == NormalizeUsername(username):
+		return reject("must differ from the username")
+	}
+
+	return nil
+}
+
+// NormalizeUsername returns the key that makes usernames unique: the username
+// case-folded and NFKC-normalized, so that names differing only in case or in
+// how their characters are encoded collide.
+func NormalizeUsername(username string) string {
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(username)))
+}
@@ -0,0 +1,166 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheckUsername(t *testing.T) {
+	p := NewPolicy()
+	p.Reserve("Moderators")
+
+	tests := []struct {
+		name     string
+		username string
+		msg      string
+	}{
+		{"valid", "alice_1", ""},
+		{"empty", "", "is required"},
+		{"too short", "ab", "must be at least 3 characters long"},
+		{"short in runes, long in bytes", "日本", "must be at least 3 characters long"},
+		{"long enough in runes", "äöü", ""},
+		{"longest", strings.Repeat("é", 32), ""},
+		{"too long", strings.Repeat("a", 33), "must be at most 32 characters long"},
+		{"space", "bad name", "contains characters that are not allowed"},
+		{"punctuation", "alice!", "contains characters that are not allowed"},
+		{"reserved", "admin", "is reserved"},
+		{"reserved in another case", "Admin", "is reserved"},
+		{"reserved in fullwidth", "ＡＤＭＩＮ", "is reserved"},
+		{"reserved by configuration", "MODERATORS", "is reserved"},
+		{"reserved prefix", "admins", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe := p.CheckUsername(tt.username)
+			checkFieldError(t, fe, "username", tt.msg)
+			if fe != nil && fe.Value != tt.username {
+				t.Errorf("value = %q, want %q", fe.Value, tt.username)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckPassword(t *testing.T) {
+	p := NewPolicy()
+	p.BlockPasswords("Hunter2Hunter2")
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		msg      string
+	}{
+		{"valid", "alice", "correct horse battery", ""},
+		{"empty", "alice", "", "is required"},
+		{"too short", "alice", "short", "must be at least 8 characters long"},
+		{"short in runes, long in bytes", "alice", "ääää", "must be at least 8 characters long"},
+		{"long enough in runes", "alice", "日本語のパスワード", ""},
+		{"bcrypt limit", "alice", strings.Repeat("x", MaxPasswordBytes), ""},
+		{"over bcrypt limit", "alice", strings.Repeat("x", MaxPasswordBytes+1), "must be at most 72 bytes long"},
+		{"over bcrypt limit in bytes only", "alice", strings.Repeat("日", 25), "must be at most 72 bytes long"},
+		{"common", "alice", "password", "is too common"},
+		{"common in another case", "alice", "PassWord", "is too common"},
+		{"blocked by configuration", "alice", "hunter2hunter2", "is too common"},
+		{"same as username", "alice_smith", "alice_smith", "must differ from the username"},
+		{"username in another case", "alice_smith", "ALICE_Smith", "must differ from the username"},
+		{"username in fullwidth", "alice_smith", "ａｌｉｃｅ＿ｓｍｉｔｈ", "must differ from the username"},
+		{"containing username", "alice_smith", "alice_smith_2", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe := p.CheckPassword(tt.username, tt.password)
+			checkFieldError(t, fe, "password", tt.msg)
+			if fe != nil && len(fe.Value) != 0 {
+				t.Errorf("error includes the password %q", fe.Value)
+			}
+		})
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := NewPolicy()
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		email    string
+		fields   []string
+	}{
+		{"valid", "alice", "correct horse battery", "alice@example.com", nil},
+		{"without email", "alice", "correct horse battery", "", nil},
+		{"bad username", "a", "correct horse battery", "", []string{"username"}},
+		{"every field", "ab", "short", "not an email", []string{"username", "password", "email"}},
+		{"password equal to username", "alice_smith", "Alice_Smith", "", []string{"password"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(tt.username, tt.password, tt.email)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("got error %v, want a *ValidationError", err)
+			}
+
+			fields := make([]string, 0, len(validationErr.Errors))
+			for _, fe := range validationErr.Errors {
+				fields = append(fields, fe.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("rejected fields %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"case", "Alice", "alice", true},
+		{"fullwidth", "ＡＬＩＣＥ", "alice", true},
+		{"composed and decomposed", "jos\u00e9", "jose\u0301", true},
+		{"ligature", "ﬁsh", "fish", true},
+		{"sharp s", "STRASSE", "straße", true},
+		{"final sigma", "ΟΔΟΣ", "οδος", true},
+		{"different letters", "alice", "alicia", false},
+		{"accent", "jose", "josé", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NormalizeUsername(tt.a), NormalizeUsername(tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("NormalizeUsername(%q) = %q, NormalizeUsername(%q) = %q, want same = %t",
+					tt.a, a, tt.b, b, tt.same)
+			}
+		})
+	}
+}
+
+// checkFieldError fails the test unless fe rejects field with msg, or is nil
+// when msg is empty.
+func checkFieldError(t *testing.T, fe *FieldError, field string, msg string) {
+	t.Helper()
+
+	switch {
+	case len(msg) == 0 && fe != nil:
+		t.Errorf("unexpected error: %s", fe)
+	case len(msg) != 0 && fe == nil:
+		t.Errorf("no error, want %q", msg)
+	case fe != nil && (fe.Field != field || fe.Msg != msg):
+		t.Errorf("got error %q, want %q", fe, field+" "+msg)
+	}
+}
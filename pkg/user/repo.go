@@ -2,37 +2,52 @@ package user
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/teatah/rclone/pkg/outbox"
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
 	ErrUserAlreadyExists = errors.New("already exists")
 	ErrUserNotFound      = errors.New("user not found")
 	ErrInvalidPassword   = errors.New("invalid password")
+	ErrUserBanned        = errors.New("user is banned")
 )
 
 type UserDBRepo struct {
 	pgPool *pgxpool.Pool
+	// Policy is checked by Register.
+	Policy *Policy
 }
 
 func NewUserDBRepo(pgPool *pgxpool.Pool) *UserDBRepo {
 	return &UserDBRepo{
 		pgPool: pgPool,
+		Policy: NewPolicy(),
 	}
 }
 
+// Register creates a user after checking the request against the policy. It
+// returns a *ValidationError for a rejected request and ErrUserAlreadyExists
+// when the username differs from a taken one only in case or encoding.
 func (ur *UserDBRepo) Register(ctx context.Context, userRequest *UserRequest) (*User, error) {
-	username := userRequest.Username
+	username := norm.NFC.String(userRequest.Username)
 	password := userRequest.Password
 
+	err := ur.Policy.Check(username, password, userRequest.Email)
+	if err != nil {
+		return nil, err
+	}
+
 	newUser, err := NewUserWithCredentials(username, password)
 	if err != nil {
 		return nil, err
 	}
+	newUser.Email = userRequest.Email
 
 	err = ur.addUser(ctx, newUser)
 	if err != nil {
@@ -43,7 +58,7 @@ func (ur *UserDBRepo) Register(ctx context.Context, userRequest *UserRequest) (*
 }
 
 func (ur *UserDBRepo) Login(ctx context.Context, userRequest *UserRequest) (*User, error) {
-	user, err := ur.UserByName(ctx, userRequest.Username)
+	user, err := ur.UserByName(ctx, norm.NFC.String(userRequest.Username))
 	if err != nil {
 		return nil, err
 	}
@@ -53,59 +68,253 @@ func (ur *UserDBRepo) Login(ctx context.Context, userRequest *UserRequest) (*Use
 		return nil, ErrInvalidPassword
 	}
 
+	if user.Banned {
+		return nil, ErrUserBanned
+	}
+
 	return user, nil
 }
 
 func (ur *UserDBRepo) UserByName(ctx context.Context, username string) (*User, error) {
-	var user User
-	err := ur.pgPool.QueryRow(
+	row := ur.pgPool.QueryRow(
 		ctx,
-		"SELECT id, username, password FROM users WHERE username=$1",
+		"SELECT "+userColumns+" FROM users WHERE username=$1",
 		username,
-	).Scan(&user.ID, &user.Username, &user.password)
+	)
+
+	return scanUser(row)
+}
 
+func (ur *UserDBRepo) UserByID(ctx context.Context, userID string) (*User, error) {
+	row := ur.pgPool.QueryRow(
+		ctx,
+		"SELECT "+userColumns+" FROM users WHERE id=$1",
+		userID,
+	)
+
+	return scanUser(row)
+}
+
+func (ur *UserDBRepo) UsersByIDs(ctx context.Context, userIDs []string) ([]*User, error) {
+	rows, err := ur.pgPool.Query(
+		ctx,
+		"SELECT "+userColumns+" FROM users WHERE id = ANY($1)",
+		userIDs,
+	)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrUserNotFound
-		}
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &user, nil
+	users := make([]*User, 0, len(userIDs))
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
 }
 
-func (ur *UserDBRepo) UserByID(ctx context.Context, userID string) (*User, error) {
-	var user User
-	err := ur.pgPool.QueryRow(
+func (ur *UserDBRepo) UpdateProfile(ctx context.Context, userID string, pr *ProfileRequest) (*User, error) {
+	row := ur.pgPool.QueryRow(
 		ctx,
-		"SELECT id, username, password FROM users WHERE id=$1",
+		`UPDATE users
+		SET display_name = COALESCE($2, display_name),
+			bio = COALESCE($3, bio),
+			avatar = COALESCE($4, avatar)
+		WHERE id = $1
+		RETURNING `+userColumns,
 		userID,
-	).Scan(&user.ID, &user.Username, &user.password)
+		pr.DisplayName,
+		pr.Bio,
+		pr.Avatar,
+	)
+
+	return scanUser(row)
+}
 
+func (ur *UserDBRepo) AddKarma(ctx context.Context, userID string, delta Karma) error {
+	_, err := ur.pgPool.Exec(
+		ctx,
+		`UPDATE users
+		SET post_karma = post_karma + $2, comment_karma = comment_karma + $3
+		WHERE id = $1`,
+		userID,
+		delta.Post,
+		delta.Comment,
+	)
+
+	return err
+}
+
+// ResetKarma replaces the karma of every user with the given values. Users
+// missing from karma are reset to zero.
+func (ur *UserDBRepo) ResetKarma(ctx context.Context, karma map[string]Karma) error {
+	tx, err := ur.pgPool.Begin(ctx)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrUserNotFound
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, "UPDATE users SET post_karma = 0, comment_karma = 0")
+	if err != nil {
+		return err
+	}
+
+	for userID, k := range karma {
+		_, err = tx.Exec(
+			ctx,
+			"UPDATE users SET post_karma = $2, comment_karma = $3 WHERE id = $1",
+			userID,
+			k.Post,
+			k.Comment,
+		)
+		if err != nil {
+			return err
 		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Users returns the users ordered by registration time.
+func (ur *UserDBRepo) Users(ctx context.Context, offset int, limit int) ([]*User, error) {
+	rows, err := ur.pgPool.Query(
+		ctx,
+		"SELECT "+userColumns+" FROM users ORDER BY created, username LIMIT $1 OFFSET $2",
+		limit,
+		offset,
+	)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &user, nil
+	users := make([]*User, 0, limit)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (ur *UserDBRepo) SetBanned(ctx context.Context, userID string, banned bool) error {
+	return ur.execForUser(ctx, "UPDATE users SET banned = $2 WHERE id = $1", userID, banned)
+}
+
+func (ur *UserDBRepo) SetPassword(ctx context.Context, userID string, password string) error {
+	hashedPass, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return ur.execForUser(ctx, "UPDATE users SET password = $2 WHERE id = $1", userID, hashedPass)
+}
+
+// DeleteUser deletes the user together with its sessions and messages. Posts
+// and comments of the user are kept.
+func (ur *UserDBRepo) DeleteUser(ctx context.Context, userID string) error {
+	return ur.execForUser(ctx, "DELETE FROM users WHERE id = $1", userID)
+}
+
+func (ur *UserDBRepo) CountUsers(ctx context.Context) (int, int, error) {
+	var users, banned int
+	err := ur.pgPool.QueryRow(
+		ctx,
+		"SELECT COUNT(*), COUNT(*) FILTER (WHERE banned) FROM users",
+	).Scan(&users, &banned)
+
+	return users, banned, err
+}
+
+// execForUser runs a statement affecting the user with the given ID and
+// reports ErrUserNotFound if there is no such user.
+func (ur *UserDBRepo) execForUser(ctx context.Context, sql string, userID string, args ...any) error {
+	tag, err := ur.pgPool.Exec(ctx, sql, append([]any{userID}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
 }
 
+// addUser inserts the user together with its UserRegistered outbox event.
 func (ur *UserDBRepo) addUser(ctx context.Context, user *User) error {
-	_, err := ur.pgPool.Exec(
+	tx, err := ur.pgPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(
 		ctx,
-		"INSERT INTO users (id, username, password) values ($1, $2, $3)",
+		`INSERT INTO users (id, username, username_key, password, created, email)
+		values ($1, $2, $3, $4, $5, NULLIF($6, ''))`,
 		user.ID,
 		user.Username,
+		NormalizeUsername(user.Username),
 		user.password,
+		user.Created,
+		user.Email,
 	)
 
 	if err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
 			return ErrUserAlreadyExists
 		}
+
+		return err
 	}
 
-	return err
+	err = outbox.Insert(ctx, tx, outbox.NewUserRegistered(user.ID, user.Username))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+const userColumns = `id, username, password, display_name, bio, avatar, created,
+	post_karma, comment_karma, banned, COALESCE(email, ''), email_verified_at IS NOT NULL`
+
+func scanUser(row pgx.Row) (*User, error) {
+	var user User
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.password,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Avatar,
+		&user.Created,
+		&user.Karma.Post,
+		&user.Karma.Comment,
+		&user.Banned,
+		&user.Email,
+		&user.EmailVerified,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
 }
@@ -0,0 +1,171 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultPasswordResetTTL is how long a password reset token stays valid.
+const DefaultPasswordResetTTL = time.Hour
+
+var ErrResetTokenInvalid = errors.New("reset token is invalid or expired")
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ChangePassword sets a new password for the user after checking the current
+// one. It returns ErrInvalidPassword when current is wrong and a
+// *ValidationError when the new password breaks the policy.
+func (ur *UserDBRepo) ChangePassword(ctx context.Context, userID string, current string, password string) error {
+	u, err := ur.UserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = u.CheckPassword(current)
+	if err != nil {
+		return ErrInvalidPassword
+	}
+
+	fe := ur.Policy.CheckPassword(u.Username, password)
+	if fe != nil {
+		fe.Field = "newPassword"
+		return &ValidationError{Errors: []*FieldError{fe}}
+	}
+
+	return ur.SetPassword(ctx, userID, password)
+}
+
+// CreatePasswordReset returns a new password reset token for the user that is
+// valid for ttl. Earlier tokens of the user stop working.
+func (ur *UserDBRepo) CreatePasswordReset(ctx context.Context, userID string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	resetToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	tx, err := ur.pgPool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, "DELETE FROM password_resets WHERE user_id = $1", userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO password_resets (id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		uuid.NewString(),
+		userID,
+		hashResetToken(resetToken),
+		time.Now().Add(ttl),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return resetToken, tx.Commit(ctx)
+}
+
+// ResetPassword sets a new password for the user the reset token was issued
+// to and returns the user. The token can be used once. It returns
+// ErrResetTokenInvalid for an unknown, used or expired token and a
+// *ValidationError when the password breaks the policy, which leaves the token
+// usable.
+func (ur *UserDBRepo) ResetPassword(ctx context.Context, resetToken string, password string) (*User, error) {
+	tx, err := ur.pgPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var (
+		resetID   string
+		userID    string
+		expiresAt time.Time
+		usedAt    *time.Time
+	)
+	err = tx.QueryRow(
+		ctx,
+		"SELECT id, user_id, expires_at, used_at FROM password_resets WHERE token_hash = $1 FOR UPDATE",
+		hashResetToken(resetToken),
+	).Scan(&resetID, &userID, &expiresAt, &usedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if usedAt != nil || time.Now().After(expiresAt) {
+		return nil, ErrResetTokenInvalid
+	}
+
+	u, err := scanUser(tx.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", userID))
+	if err != nil {
+		return nil, err
+	}
+
+	fe := ur.Policy.CheckPassword(u.Username, password)
+	if fe != nil {
+		return nil, &ValidationError{Errors: []*FieldError{fe}}
+	}
+
+	hashedPass, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE users SET password = $2 WHERE id = $1", userID, hashedPass)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE password_resets SET used_at = NOW() WHERE id = $1", resetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, tx.Commit(ctx)
+}
+
+// RemoveExpiredPasswordResets deletes the reset tokens that can no longer be
+// used.
+func (ur *UserDBRepo) RemoveExpiredPasswordResets(ctx context.Context) error {
+	_, err := ur.pgPool.Exec(ctx, "DELETE FROM password_resets WHERE expires_at < NOW() OR used_at IS NOT NULL")
+	return err
+}
+
+// hashResetToken hashes a password reset token for storage. The tokens are
+// random, so a plain SHA-256 is enough.
+func hashResetToken(resetToken string) []byte {
+	sum := sha256.Sum256([]byte(resetToken))
+	return sum[:]
+}
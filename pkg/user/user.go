@@ -2,6 +2,8 @@ package user
 
 import (
 	"context"
+	"net/mail"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -10,12 +12,56 @@ import (
 type UserRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// Email is optional; it is verified through a link mailed to it.
+	Email string `json:"email,omitempty"`
 }
 
 type User struct {
-	ID       string
-	Username string
-	password []byte
+	ID          string
+	Username    string
+	DisplayName string
+	Bio         string
+	Avatar      string
+	Created     time.Time
+	Karma       Karma
+	Banned      bool
+	// Email is where password reset links are sent to once it is verified.
+	Email         string
+	EmailVerified bool
+	password      []byte
+}
+
+// Profile is the public view of a user.
+type Profile struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	DisplayName  string    `json:"displayName"`
+	Bio          string    `json:"bio"`
+	Avatar       string    `json:"avatar"`
+	Created      time.Time `json:"created"`
+	Karma        Karma     `json:"karma"`
+	PostCount    int       `json:"postCount"`
+	CommentCount int       `json:"commentCount"`
+}
+
+// ProfileRequest holds the profile fields to change. Nil fields are left as
+// they are.
+type ProfileRequest struct {
+	DisplayName *string `json:"displayName,omitempty"`
+	Bio         *string `json:"bio,omitempty"`
+	Avatar      *string `json:"avatar,omitempty"`
+}
+
+const (
+	MaxDisplayNameLen = 55
+	MaxBioLen         = 500
+	MaxAvatarLen      = 255
+)
+
+// Karma is the total score of the posts and comments written by a user.
+type Karma struct {
+	Post    int `json:"post"`
+	Comment int `json:"comment"`
 }
 
 type UserRepo interface {
@@ -23,6 +69,22 @@ type UserRepo interface {
 	Login(context.Context, *UserRequest) (*User, error)
 	UserByName(context.Context, string) (*User, error)
 	UserByID(context.Context, string) (*User, error)
+	UsersByIDs(ctx context.Context, userIDs []string) ([]*User, error)
+	UpdateProfile(ctx context.Context, userID string, pr *ProfileRequest) (*User, error)
+	AddKarma(ctx context.Context, userID string, delta Karma) error
+	ResetKarma(ctx context.Context, karma map[string]Karma) error
+	Users(ctx context.Context, offset int, limit int) ([]*User, error)
+	SetBanned(ctx context.Context, userID string, banned bool) error
+	SetPassword(ctx context.Context, userID string, password string) error
+	ChangePassword(ctx context.Context, userID string, current string, password string) error
+	CreatePasswordReset(ctx context.Context, userID string, ttl time.Duration) (string, error)
+	ResetPassword(ctx context.Context, resetToken string, password string) (*User, error)
+	SetEmail(ctx context.Context, userID string, email string, verified bool) error
+	VerifyEmail(ctx context.Context, userID string, email string) error
+	MarkVerificationSent(ctx context.Context, userID string, interval time.Duration) error
+	CanPost(u *User) error
+	DeleteUser(ctx context.Context, userID string) error
+	CountUsers(ctx context.Context) (users int, banned int, err error)
 }
 
 func (u *User) CheckPassword(password string) error {
@@ -33,12 +95,19 @@ func (u *User) CheckPassword(password string) error {
 	return nil
 }
 
+// ValidEmail reports whether email is a bare email address.
+func ValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
 func NewUserWithCredentials(username string, password string) (*User, error) {
 	userID := uuid.NewString()
 
 	newUser := &User{
 		ID:       userID,
 		Username: username,
+		Created:  time.Now().UTC(),
 	}
 
 	err := newUser.setPassword(password)
@@ -49,8 +118,20 @@ func NewUserWithCredentials(username string, password string) (*User, error) {
 	return newUser, nil
 }
 
+func (u *User) Profile() *Profile {
+	return &Profile{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		Avatar:      u.Avatar,
+		Created:     u.Created,
+		Karma:       u.Karma,
+	}
+}
+
 func (u *User) setPassword(password string) error {
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPass, err := hashPassword(password)
 	if err != nil {
 		return err
 	}
@@ -59,3 +140,7 @@ func (u *User) setPassword(password string) error {
 
 	return nil
 }
+
+func hashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
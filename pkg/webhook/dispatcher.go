@@ -0,0 +1,280 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/teatah/rclone/pkg/post"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMaxAttempts = 6
+	DefaultBackoff     = 30 * time.Second
+	DefaultTimeout     = 10 * time.Second
+	DefaultWorkers     = 4
+
+	// recordTimeout bounds recording the deliveries of a published event.
+	recordTimeout = 5 * time.Second
+	pollInterval  = 5 * time.Second
+	pollBatch     = 50
+)
+
+// Dispatcher delivers post events to the matching webhooks. Publish records a
+// pending delivery for every matching webhook; Run sends due deliveries with
+// a pool of Workers and retries failed attempts with exponential backoff.
+type Dispatcher struct {
+	repo   WebhookRepo
+	client *http.Client
+	logger *zap.SugaredLogger
+	// wake makes Run look for due deliveries before the next poll.
+	wake chan struct{}
+	// inFlight holds the IDs of the deliveries being sent, so a poll does
+	// not hand them to a second worker.
+	inFlightMu  *sync.Mutex
+	inFlight    map[string]bool
+	MaxAttempts int
+	Backoff     time.Duration
+	Workers     int
+}
+
+func NewDispatcher(repo WebhookRepo, client *http.Client, logger *zap.SugaredLogger) *Dispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return &Dispatcher{
+		repo:        repo,
+		client:      client,
+		logger:      logger,
+		wake:        make(chan struct{}, 1),
+		inFlightMu:  &sync.Mutex{},
+		inFlight:    make(map[string]bool),
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		Workers:     DefaultWorkers,
+	}
+}
+
+// Publish records the deliveries of the event before returning, so they are
+// sent even if the process stops before Run gets to them.
+func (d *Dispatcher) Publish(e *post.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	err := d.record(ctx, e)
+	if err != nil {
+		d.logger.Errorf("failed to record webhook deliveries of %s event for post %s: %s", e.Type, e.Post.ID, err)
+		return
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	jobs := make(chan *Delivery)
+	for i := 0; i < d.Workers; i++ {
+		go d.work(ctx, jobs)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+
+		err := d.poll(ctx, jobs)
+		if err != nil {
+			d.logger.Errorf("failed to poll webhook deliveries: %s", err)
+		}
+	}
+}
+
+// record adds a pending delivery of the event for every matching webhook.
+func (d *Dispatcher) record(ctx context.Context, e *post.Event) error {
+	webhooks, err := d.repo.Matching(ctx, e.Type, e.Post.Category)
+	if err != nil {
+		return err
+	}
+
+	for i := range *webhooks {
+		w := &(*webhooks)[i]
+
+		delivery := NewDelivery(w.ID, e.Type)
+
+		payload, err := json.Marshal(&Payload{
+			Event:     e.Type,
+			Delivery:  delivery.ID,
+			Created:   delivery.Created,
+			Post:      e.Post,
+			CommentID: e.CommentID,
+		})
+		if err != nil {
+			return err
+		}
+		delivery.Payload = string(payload)
+
+		err = d.repo.AddDelivery(ctx, delivery)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// poll hands the due deliveries that are not being sent to the workers. It
+// polls again right away when it got a full batch.
+func (d *Dispatcher) poll(ctx context.Context, jobs chan<- *Delivery) error {
+	deliveries, err := d.repo.DueDeliveries(ctx, time.Now().UTC(), pollBatch+d.Workers)
+	if err != nil {
+		return err
+	}
+
+	for i := range *deliveries {
+		delivery := &(*deliveries)[i]
+		if !d.claim(delivery.ID) {
+			continue
+		}
+
+		select {
+		case jobs <- delivery:
+		case <-ctx.Done():
+			d.release(delivery.ID)
+			return nil
+		}
+	}
+
+	if len(*deliveries) == pollBatch+d.Workers {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) work(ctx context.Context, jobs <-chan *Delivery) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case delivery := <-jobs:
+			err := d.deliver(ctx, delivery)
+			if err != nil {
+				d.logger.Errorf("failed to deliver webhook delivery %s: %s", delivery.ID, err)
+			}
+			d.release(delivery.ID)
+		}
+	}
+}
+
+// deliver makes the next attempt of the delivery, or fails it if its webhook
+// has been deleted.
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) error {
+	w, err := d.repo.Webhook(ctx, delivery.WebhookID)
+	if errors.Is(err, ErrWebhookNotFound) {
+		delivery.Status = DeliveryFailed
+		return d.repo.UpdateDelivery(ctx, delivery)
+	}
+	if err != nil {
+		return err
+	}
+
+	return d.attempt(ctx, w, delivery)
+}
+
+// claim marks the delivery as being sent and reports whether it was not
+// already.
+func (d *Dispatcher) claim(deliveryID string) bool {
+	d.inFlightMu.Lock()
+	defer d.inFlightMu.Unlock()
+
+	if d.inFlight[deliveryID] {
+		return false
+	}
+	d.inFlight[deliveryID] = true
+
+	return true
+}
+
+func (d *Dispatcher) release(deliveryID string) {
+	d.inFlightMu.Lock()
+	defer d.inFlightMu.Unlock()
+
+	delete(d.inFlight, deliveryID)
+}
+
+// attempt sends the delivery once, records the attempt and schedules the next
+// one if it failed.
+func (d *Dispatcher) attempt(ctx context.Context, w *Webhook, delivery *Delivery) error {
+	started := time.Now()
+	statusCode, sendErr := d.send(ctx, w, delivery)
+
+	attempt := &Attempt{
+		At:         started.UTC(),
+		StatusCode: statusCode,
+		Duration:   time.Since(started).Milliseconds(),
+	}
+	if sendErr != nil {
+		attempt.Error = sendErr.Error()
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = DeliverySucceeded
+	case len(delivery.Attempts) >= d.MaxAttempts:
+		delivery.Status = DeliveryFailed
+	default:
+		backoff := d.Backoff << (len(delivery.Attempts) - 1)
+		delivery.NextAttempt = time.Now().UTC().Add(backoff)
+	}
+
+	return d.repo.UpdateDelivery(ctx, delivery)
+}
+
+func (d *Dispatcher) send(ctx context.Context, w *Webhook, delivery *Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(w.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,309 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teatah/rclone/pkg/post"
+	"go.uber.org/zap"
+)
+
+// fakeRepo keeps webhooks and deliveries in memory.
+type fakeRepo struct {
+	mu         sync.Mutex
+	webhooks   []Webhook
+	deliveries []*Delivery
+}
+
+func (fr *fakeRepo) Create(_ context.Context, w *Webhook) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.webhooks = append(fr.webhooks, *w)
+
+	return nil
+}
+
+func (fr *fakeRepo) Webhooks(_ context.Context) (*[]Webhook, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	webhooks := append([]Webhook(nil), fr.webhooks...)
+
+	return &webhooks, nil
+}
+
+func (fr *fakeRepo) Webhook(_ context.Context, webhookID string) (*Webhook, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for _, w := range fr.webhooks {
+		if w.ID == webhookID {
+			return &w, nil
+		}
+	}
+
+	return nil, ErrWebhookNotFound
+}
+
+func (fr *fakeRepo) Delete(_ context.Context, webhookID string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for i, w := range fr.webhooks {
+		if w.ID == webhookID {
+			fr.webhooks = append(fr.webhooks[:i], fr.webhooks[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrWebhookNotFound
+}
+
+func (fr *fakeRepo) Matching(_ context.Context, eventType string, category string) (*[]Webhook, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	webhooks := make([]Webhook, 0)
+	for _, w := range fr.webhooks {
+		if len(w.Category) != 0 && w.Category != category {
+			continue
+		}
+		for _, e := range w.Events {
+			if e == eventType {
+				webhooks = append(webhooks, w)
+				break
+			}
+		}
+	}
+
+	return &webhooks, nil
+}
+
+func (fr *fakeRepo) AddDelivery(_ context.Context, d *Delivery) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	stored := *d
+	fr.deliveries = append(fr.deliveries, &stored)
+
+	return nil
+}
+
+func (fr *fakeRepo) UpdateDelivery(_ context.Context, d *Delivery) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for _, stored := range fr.deliveries {
+		if stored.ID == d.ID {
+			stored.Status = d.Status
+			stored.Attempts = append([]*Attempt(nil), d.Attempts...)
+			stored.NextAttempt = d.NextAttempt
+		}
+	}
+
+	return nil
+}
+
+func (fr *fakeRepo) DueDeliveries(_ context.Context, now time.Time, limit int) (*[]Delivery, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	deliveries := make([]Delivery, 0, limit)
+	for _, d := range fr.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttempt.After(now) && len(deliveries) < limit {
+			deliveries = append(deliveries, *d)
+		}
+	}
+
+	return &deliveries, nil
+}
+
+func (fr *fakeRepo) Deliveries(_ context.Context, webhookID string, offset int, limit int) (*[]Delivery, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	deliveries := make([]Delivery, 0, limit)
+	for _, d := range fr.deliveries {
+		if d.WebhookID == webhookID {
+			deliveries = append(deliveries, *d)
+		}
+	}
+
+	return &deliveries, nil
+}
+
+// delivery returns a copy of the only delivery recorded.
+func (fr *fakeRepo) delivery(t *testing.T) *Delivery {
+	t.Helper()
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if len(fr.deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(fr.deliveries))
+	}
+	d := *fr.deliveries[0]
+
+	return &d
+}
+
+func newTestDispatcher(t *testing.T, handler http.HandlerFunc, webhooks ...*Webhook) (*Dispatcher, *fakeRepo) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	repo := &fakeRepo{}
+	for _, w := range webhooks {
+		w.URL = server.URL
+		err := repo.Create(context.Background(), w)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewDispatcher(repo, server.Client(), zap.NewNop().Sugar()), repo
+}
+
+func newTestWebhook(t *testing.T, category string, events ...string) *Webhook {
+	t.Helper()
+
+	w, err := NewWebhook(&WebhookRequest{Events: events, Category: category})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func newTestEvent(category string) *post.Event {
+	return &post.Event{
+		Type: post.EventPostCreated,
+		Post: &post.Post{ID: "post1", Category: category, Title: "title"},
+	}
+}
+
+func TestDispatcherSignsDeliveries(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+
+	w := newTestWebhook(t, "", post.EventPostCreated)
+	d, repo := newTestDispatcher(t, func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+	}, w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Publish(newTestEvent("music"))
+
+	var req request
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery within 5s")
+	}
+
+	timestamp := req.header.Get(HeaderTimestamp)
+	want := Sign(w.Secret, timestamp, req.body)
+	if got := req.header.Get(HeaderSignature); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+	if got := req.header.Get(HeaderEvent); got != post.EventPostCreated {
+		t.Errorf("%s = %q, want %q", HeaderEvent, got, post.EventPostCreated)
+	}
+	if Sign("other secret", timestamp, req.body) == want {
+		t.Error("signature does not depend on the secret")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for repo.delivery(t).Status != DeliverySucceeded {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery status is %q, want %q", repo.delivery(t).Status, DeliverySucceeded)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := req.header.Get(HeaderDelivery); got != repo.delivery(t).ID {
+		t.Errorf("%s = %q, want %q", HeaderDelivery, got, repo.delivery(t).ID)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	w := newTestWebhook(t, "", post.EventPostCreated)
+	d, repo := newTestDispatcher(t, func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}, w)
+	d.MaxAttempts = 3
+	d.Backoff = time.Minute
+
+	ctx := context.Background()
+	d.Publish(newTestEvent(""))
+
+	for n := 1; n <= d.MaxAttempts; n++ {
+		before := time.Now().UTC()
+		err := d.deliver(ctx, repo.delivery(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		after := time.Now().UTC()
+
+		delivery := repo.delivery(t)
+		if len(delivery.Attempts) != n {
+			t.Fatalf("got %d attempts, want %d", len(delivery.Attempts), n)
+		}
+		if got := delivery.Attempts[n-1].StatusCode; got != http.StatusServiceUnavailable {
+			t.Errorf("attempt %d status code = %d, want %d", n, got, http.StatusServiceUnavailable)
+		}
+
+		if n == d.MaxAttempts {
+			if delivery.Status != DeliveryFailed {
+				t.Errorf("status after %d attempts = %q, want %q", n, delivery.Status, DeliveryFailed)
+			}
+			break
+		}
+
+		if delivery.Status != DeliveryPending {
+			t.Errorf("status after attempt %d = %q, want %q", n, delivery.Status, DeliveryPending)
+		}
+		backoff := d.Backoff << (n - 1)
+		if delivery.NextAttempt.Before(before.Add(backoff)) || delivery.NextAttempt.After(after.Add(backoff)) {
+			t.Errorf("next attempt after attempt %d is in %s, want %s",
+				n, delivery.NextAttempt.Sub(before), backoff)
+		}
+	}
+}
+
+func TestDispatcherFiltersByCategory(t *testing.T) {
+	uncategorized := newTestWebhook(t, "", post.EventPostCreated)
+	music := newTestWebhook(t, "music", post.EventPostCreated)
+	news := newTestWebhook(t, "news", post.EventPostCreated)
+	deletions := newTestWebhook(t, "", post.EventPostDeleted)
+
+	d, repo := newTestDispatcher(t, func(rw http.ResponseWriter, r *http.Request) {}, uncategorized, music, news, deletions)
+
+	d.Publish(newTestEvent("music"))
+
+	got := make([]string, 0)
+	for _, delivery := range repo.deliveries {
+		got = append(got, delivery.WebhookID)
+	}
+	want := []string{uncategorized.ID, music.ID}
+	sort.Strings(got)
+	sort.Strings(want)
+
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("deliveries for webhooks %v, want %v", got, want)
+	}
+}
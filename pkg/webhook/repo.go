@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookDBRepo struct {
+	webhooksColl   *mongo.Collection
+	deliveriesColl *mongo.Collection
+}
+
+func NewWebhookDBRepo(webhooksCollection *mongo.Collection, deliveriesCollection *mongo.Collection) *WebhookDBRepo {
+	return &WebhookDBRepo{
+		webhooksColl:   webhooksCollection,
+		deliveriesColl: deliveriesCollection,
+	}
+}
+
+func (wr *WebhookDBRepo) Create(ctx context.Context, w *Webhook) error {
+	_, err := wr.webhooksColl.InsertOne(ctx, w)
+
+	return err
+}
+
+func (wr *WebhookDBRepo) Webhooks(ctx context.Context) (*[]Webhook, error) {
+	webhooks := make([]Webhook, 0)
+
+	opt := options.Find().SetSort(bson.D{{Key: "created", Value: -1}})
+	cur, err := wr.webhooksColl.Find(ctx, bson.M{}, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.All(ctx, &webhooks)
+
+	return &webhooks, err
+}
+
+func (wr *WebhookDBRepo) Webhook(ctx context.Context, webhookID string) (*Webhook, error) {
+	w := &Webhook{}
+
+	err := wr.webhooksColl.FindOne(ctx, bson.M{"id": webhookID}).Decode(w)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrWebhookNotFound
+	}
+
+	return w, err
+}
+
+func (wr *WebhookDBRepo) Delete(ctx context.Context, webhookID string) error {
+	res, err := wr.webhooksColl.DeleteOne(ctx, bson.M{"id": webhookID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+
+	_, err = wr.deliveriesColl.DeleteMany(ctx, bson.M{"webhookId": webhookID})
+
+	return err
+}
+
+// Matching returns the webhooks subscribed to the event type that have no
+// category filter or filter by the given category.
+func (wr *WebhookDBRepo) Matching(ctx context.Context, eventType string, category string) (*[]Webhook, error) {
+	filter := bson.M{
+		"events": eventType,
+		"$or": bson.A{
+			bson.M{"category": bson.M{"$exists": false}},
+			bson.M{"category": category},
+		},
+	}
+
+	webhooks := make([]Webhook, 0)
+	cur, err := wr.webhooksColl.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.All(ctx, &webhooks)
+
+	return &webhooks, err
+}
+
+func (wr *WebhookDBRepo) AddDelivery(ctx context.Context, d *Delivery) error {
+	_, err := wr.deliveriesColl.InsertOne(ctx, d)
+
+	return err
+}
+
+func (wr *WebhookDBRepo) UpdateDelivery(ctx context.Context, d *Delivery) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":      d.Status,
+			"attempts":    d.Attempts,
+			"nextAttempt": d.NextAttempt,
+		},
+	}
+
+	_, err := wr.deliveriesColl.UpdateOne(ctx, bson.M{"_id": d.BSONID}, update)
+
+	return err
+}
+
+func (wr *WebhookDBRepo) DueDeliveries(ctx context.Context, now time.Time, limit int) (*[]Delivery, error) {
+	filter := bson.M{
+		"status":      DeliveryPending,
+		"nextAttempt": bson.M{"$lte": now},
+	}
+
+	opt := options.Find().
+		SetSort(bson.D{{Key: "nextAttempt", Value: 1}}).
+		SetLimit(int64(limit))
+
+	deliveries := make([]Delivery, 0, limit)
+	cur, err := wr.deliveriesColl.Find(ctx, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.All(ctx, &deliveries)
+
+	return &deliveries, err
+}
+
+func (wr *WebhookDBRepo) Deliveries(ctx context.Context, webhookID string, offset int, limit int) (*[]Delivery, error) {
+	opt := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	deliveries := make([]Delivery, 0, limit)
+	cur, err := wr.deliveriesColl.Find(ctx, bson.M{"webhookId": webhookID}, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.All(ctx, &deliveries)
+
+	return &deliveries, err
+}
@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/teatah/rclone/pkg/post"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	HeaderEvent     = "X-Rclone-Event"
+	HeaderDelivery  = "X-Rclone-Delivery"
+	HeaderTimestamp = "X-Rclone-Timestamp"
+	HeaderSignature = "X-Rclone-Signature"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// EventTypes lists the events a webhook can subscribe to.
+var EventTypes = []string{
+	post.EventPostCreated,
+	post.EventPostDeleted,
+	post.EventPostVoted,
+	post.EventCommentCreated,
+	post.EventCommentDeleted,
+	post.EventCommentVoted,
+}
+
+type WebhookRequest struct {
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret,omitempty"`
+	Events   []string `json:"events"`
+	Category string   `json:"category,omitempty"`
+}
+
+type Webhook struct {
+	BSONID   primitive.ObjectID `json:"-" bson:"_id"`
+	ID       string             `json:"id" bson:"id"`
+	URL      string             `json:"url" bson:"url"`
+	Secret   string             `json:"-" bson:"secret"`
+	Events   []string           `json:"events" bson:"events"`
+	Category string             `json:"category,omitempty" bson:"category,omitempty"`
+	Created  time.Time          `json:"created" bson:"created"`
+}
+
+// CreatedWebhook is the response to registering a webhook, the only one that
+// includes its secret.
+type CreatedWebhook struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
+
+// Payload is the JSON body sent to webhook receivers.
+type Payload struct {
+	Event     string     `json:"event"`
+	Delivery  string     `json:"delivery"`
+	Created   time.Time  `json:"created"`
+	Post      *post.Post `json:"post"`
+	CommentID string     `json:"commentId,omitempty"`
+}
+
+type Attempt struct {
+	At         time.Time `json:"at" bson:"at"`
+	StatusCode int       `json:"statusCode,omitempty" bson:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+	Duration   int64     `json:"durationMs" bson:"durationMs"`
+}
+
+// Delivery is one event sent to one webhook together with the log of its
+// attempts.
+type Delivery struct {
+	BSONID      primitive.ObjectID `json:"-" bson:"_id"`
+	ID          string             `json:"id" bson:"id"`
+	WebhookID   string             `json:"webhookId" bson:"webhookId"`
+	Event       string             `json:"event" bson:"event"`
+	Payload     string             `json:"payload" bson:"payload"`
+	Status      string             `json:"status" bson:"status"`
+	Attempts    []*Attempt         `json:"attempts" bson:"attempts"`
+	NextAttempt time.Time          `json:"nextAttempt" bson:"nextAttempt"`
+	Created     time.Time          `json:"created" bson:"created"`
+}
+
+type WebhookRepo interface {
+	Create(ctx context.Context, w *Webhook) error
+	Webhooks(ctx context.Context) (*[]Webhook, error)
+	Webhook(ctx context.Context, webhookID string) (*Webhook, error)
+	Delete(ctx context.Context, webhookID string) error
+	Matching(ctx context.Context, eventType string, category string) (*[]Webhook, error)
+	AddDelivery(ctx context.Context, d *Delivery) error
+	UpdateDelivery(ctx context.Context, d *Delivery) error
+	DueDeliveries(ctx context.Context, now time.Time, limit int) (*[]Delivery, error)
+	Deliveries(ctx context.Context, webhookID string, offset int, limit int) (*[]Delivery, error)
+}
+
+func NewWebhook(wr *WebhookRequest) (*Webhook, error) {
+	secret := wr.Secret
+	if len(secret) == 0 {
+		generated, err := NewSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+
+	bsonID := primitive.NewObjectID()
+
+	return &Webhook{
+		BSONID:   bsonID,
+		ID:       bsonID.Hex(),
+		URL:      wr.URL,
+		Secret:   secret,
+		Events:   wr.Events,
+		Category: wr.Category,
+		Created:  time.Now().UTC(),
+	}, nil
+}
+
+func NewDelivery(webhookID string, event string) *Delivery {
+	bsonID := primitive.NewObjectID()
+	now := time.Now().UTC()
+
+	return &Delivery{
+		BSONID:      bsonID,
+		ID:          bsonID.Hex(),
+		WebhookID:   webhookID,
+		Event:       event,
+		Status:      DeliveryPending,
+		Attempts:    make([]*Attempt, 0),
+		NextAttempt: now,
+		Created:     now,
+	}
+}
+
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// Sign returns the value of the signature header for the payload. Receivers
+// recompute it over the timestamp header, a dot and the raw body.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func IsValidEvent(event string) bool {
+	for _, e := range EventTypes {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}